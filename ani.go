@@ -0,0 +1,194 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ANIHeader is the contents of the "anih" chunk of .ani files.
+type ANIHeader struct {
+	Size        uint32
+	NumFrames   uint32
+	NumSteps    uint32
+	Width       uint32
+	Height      uint32
+	BitCount    uint32
+	NumPlanes   uint32
+	DisplayRate uint32
+	Flags       uint32
+}
+
+const SizeOfANIHeader = 36
+
+// Enumeration of ANIHeader flags.
+const (
+	// ANIFlagIcon is set when the frames are stored as .ico or .cur files.
+	ANIFlagIcon = 0x1
+
+	// ANIFlagSequence is set when the file has a "seq " chunk.
+	ANIFlagSequence = 0x2
+)
+
+// DefaultANIDisplayRate is the display rate used when none is given, in
+// jiffies (1/60 s).
+const DefaultANIDisplayRate = 10
+
+// AnimatedCursor is an animated cursor or icon, stored as a RIFF "ACON"
+// file. In executables, the file is stored as-is as a ResourceAnimatedCursor
+// or ResourceAnimatedIcon resource.
+type AnimatedCursor struct {
+	Name ResourceName
+
+	ResourceLanguage
+
+	// Icon marks an animated icon: the frames are stored as .ico files, and
+	// the hotspots are ignored.
+	Icon bool
+
+	// Frames holds the frames of the animation. Each frame may have more
+	// than one image.
+	Frames []*CursorGroup
+
+	// DisplayRate is the default time each step is shown, in jiffies
+	// (1/60 s). If zero, DefaultANIDisplayRate is used.
+	DisplayRate uint32
+
+	// Rates optionally holds the time each step is shown, in jiffies.
+	Rates []uint32
+
+	// Sequence optionally holds the index of the frame shown at each step.
+	// If empty, each frame is shown once, in order.
+	Sequence []uint32
+
+	// Title and Artist are stored in the INFO list. Optional.
+	Title  string
+	Artist string
+}
+
+func (a *AnimatedCursor) numSteps() int {
+	if len(a.Sequence) != 0 {
+		return len(a.Sequence)
+	}
+	return len(a.Frames)
+}
+
+// check verifies that the animation is well-formed.
+func (a *AnimatedCursor) check() error {
+	if len(a.Frames) == 0 {
+		return errors.New("animation has no frames")
+	}
+	for i, frame := range a.Frames {
+		if len(frame.Images) == 0 || len(frame.Hotspots) != len(frame.Images) {
+			return fmt.Errorf("frame %d: expected one hotspot for each image", i+1)
+		}
+	}
+	if len(a.Rates) != 0 && len(a.Rates) != a.numSteps() {
+		return fmt.Errorf("animation has %d steps, but %d rates", a.numSteps(), len(a.Rates))
+	}
+	for i, frame := range a.Sequence {
+		if int(frame) >= len(a.Frames) {
+			return fmt.Errorf("step %d: no such frame %d", i+1, frame)
+		}
+	}
+	return nil
+}
+
+// WriteANI writes the animation as a .ani file.
+func (a *AnimatedCursor) WriteANI(w io.Writer) error {
+	if err := a.check(); err != nil {
+		return err
+	}
+
+	body := bytes.Buffer{}
+	body.WriteString("ACON")
+
+	if a.Title != "" || a.Artist != "" {
+		info := bytes.Buffer{}
+		info.WriteString("INFO")
+		for _, field := range []struct{ id, value string }{{"INAM", a.Title}, {"IART", a.Artist}} {
+			if field.value == "" {
+				continue
+			}
+			value, err := ansiz(field.value, a.codepage())
+			if err != nil {
+				return err
+			}
+			writeRIFFChunk(&info, field.id, value)
+		}
+		writeRIFFChunk(&body, "LIST", info.Bytes())
+	}
+
+	flags := uint32(ANIFlagIcon)
+	if len(a.Sequence) != 0 {
+		flags |= ANIFlagSequence
+	}
+	rate := a.DisplayRate
+	if rate == 0 {
+		rate = DefaultANIDisplayRate
+	}
+	header := bytes.Buffer{}
+	must(binary.Write(&header, binary.LittleEndian, ANIHeader{
+		Size:        SizeOfANIHeader,
+		NumFrames:   uint32(len(a.Frames)),
+		NumSteps:    uint32(a.numSteps()),
+		DisplayRate: rate,
+		Flags:       flags,
+	}), "writing ani header")
+	writeRIFFChunk(&body, "anih", header.Bytes())
+
+	if len(a.Rates) != 0 {
+		writeRIFFChunk(&body, "rate", dwords(a.Rates))
+	}
+	if len(a.Sequence) != 0 {
+		writeRIFFChunk(&body, "seq ", dwords(a.Sequence))
+	}
+
+	frames := bytes.Buffer{}
+	frames.WriteString("fram")
+	for _, frame := range a.Frames {
+		buf := bytes.Buffer{}
+		if a.Icon {
+			frame.WriteICO(&buf)
+		} else {
+			frame.WriteCUR(&buf)
+		}
+		writeRIFFChunk(&frames, "icon", buf.Bytes())
+	}
+	writeRIFFChunk(&body, "LIST", frames.Bytes())
+
+	writeRIFFChunk(w, "RIFF", body.Bytes())
+	return nil
+}
+
+// writeRIFFChunk writes a RIFF chunk, padded to an even size.
+func writeRIFFChunk(w io.Writer, id string, data []byte) {
+	_, err := io.WriteString(w, id)
+	must(err, "writing riff chunk id")
+	must(binary.Write(w, binary.LittleEndian, uint32(len(data))), "writing riff chunk size")
+	_, err = w.Write(data)
+	must(err, "writing riff chunk")
+	if len(data)%2 != 0 {
+		_, err = w.Write([]byte{0})
+		must(err, "writing riff chunk padding")
+	}
+}
+
+func dwords(values []uint32) []byte {
+	buf := []byte{}
+	for _, v := range values {
+		buf = binary.LittleEndian.AppendUint32(buf, v)
+	}
+	return buf
+}
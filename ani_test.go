@@ -0,0 +1,129 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// riffChunk is a decoded RIFF chunk. The data of RIFF and LIST chunks is
+// decoded into their form type and sub-chunks.
+type riffChunk struct {
+	id     string
+	data   []byte
+	form   string
+	chunks []riffChunk
+}
+
+// parseRIFF decodes the chunks of data, checking their padding.
+func parseRIFF(t *testing.T, data []byte) []riffChunk {
+	t.Helper()
+	chunks := []riffChunk{}
+	for len(data) != 0 {
+		if len(data) < 8 {
+			t.Fatalf("truncated chunk header % x", data)
+		}
+		c := riffChunk{id: string(data[:4])}
+		size := int(binary.LittleEndian.Uint32(data[4:]))
+		padded := size + size%2
+		if 8+padded > len(data) {
+			t.Fatalf("chunk %q of %d bytes is truncated", c.id, size)
+		}
+		c.data = data[8 : 8+size]
+		if c.id == "RIFF" || c.id == "LIST" {
+			c.form = string(c.data[:4])
+			c.chunks = parseRIFF(t, c.data[4:])
+		}
+		chunks = append(chunks, c)
+		data = data[8+padded:]
+	}
+	return chunks
+}
+
+func testAnimatedCursor(t *testing.T) *AnimatedCursor {
+	t.Helper()
+	return &AnimatedCursor{
+		Frames:   []*CursorGroup{testCursorGroup(t), testCursorGroup(t)},
+		Rates:    []uint32{5, 5, 10},
+		Sequence: []uint32{0, 1, 0},
+		Title:    "Mock",
+	}
+}
+
+func TestAnimatedCursorWriteANI(t *testing.T) {
+	a := testAnimatedCursor(t)
+	buf := bytes.Buffer{}
+	if err := a.WriteANI(&buf); err != nil {
+		t.Fatal(err)
+	}
+	riff := parseRIFF(t, buf.Bytes())
+	if len(riff) != 1 || riff[0].id != "RIFF" || riff[0].form != "ACON" {
+		t.Fatalf("not a RIFF ACON file: %+v", riff)
+	}
+	chunks := riff[0].chunks
+	ids := []string{}
+	for _, c := range chunks {
+		ids = append(ids, c.id+c.form)
+	}
+	if want := []string{"LISTINFO", "anih", "rate", "seq ", "LISTfram"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("chunks %q, want %q", ids, want)
+	}
+
+	info := chunks[0].chunks
+	if len(info) != 1 || info[0].id != "INAM" || string(info[0].data) != "Mock\x00" {
+		t.Errorf("INFO list %+v", info)
+	}
+	header := ANIHeader{}
+	if err := binary.Read(bytes.NewReader(chunks[1].data), binary.LittleEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	want := ANIHeader{
+		Size:        SizeOfANIHeader,
+		NumFrames:   2,
+		NumSteps:    3,
+		DisplayRate: DefaultANIDisplayRate,
+		Flags:       ANIFlagIcon | ANIFlagSequence,
+	}
+	if header != want {
+		t.Errorf("header %+v, want %+v", header, want)
+	}
+	if !bytes.Equal(chunks[2].data, dwords(a.Rates)) || !bytes.Equal(chunks[3].data, dwords(a.Sequence)) {
+		t.Errorf("rate % x, seq % x", chunks[2].data, chunks[3].data)
+	}
+
+	frames := chunks[4].chunks
+	if len(frames) != 2 {
+		t.Fatalf("%d frames, want 2", len(frames))
+	}
+	cur := bytes.Buffer{}
+	a.Frames[0].WriteCUR(&cur)
+	for i, frame := range frames {
+		if frame.id != "icon" || !bytes.Equal(frame.data, cur.Bytes()) {
+			t.Errorf("frame %d is not the .cur file", i+1)
+		}
+	}
+}
+
+func TestAnimatedCursorErrors(t *testing.T) {
+	for _, modify := range []func(a *AnimatedCursor){
+		func(a *AnimatedCursor) { a.Frames = nil },
+		func(a *AnimatedCursor) { a.Rates = a.Rates[:2] },
+		func(a *AnimatedCursor) { a.Sequence[2] = 2 },
+	} {
+		a := testAnimatedCursor(t)
+		modify(a)
+		if err := a.WriteANI(&bytes.Buffer{}); err == nil {
+			t.Errorf("WriteANI of %+v succeeded", a)
+		}
+	}
+}
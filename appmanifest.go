@@ -0,0 +1,159 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Conventional IDs of ResourceManifest resources.
+const (
+	// CreateProcessManifestResourceID is used by executables.
+	CreateProcessManifestResourceID = 1
+
+	// IsolationAwareManifestResourceID is used by DLLs.
+	IsolationAwareManifestResourceID = 2
+)
+
+// supportedOSGUIDs maps short names to the GUIDs used in the supportedOS
+// element of application manifests.
+var supportedOSGUIDs = map[string]string{
+	"vista": "{e2011457-1546-43c5-a5fe-008deee3d3f0}",
+	"win7":  "{35138b9a-5d96-4fbd-8e2d-a2440225f93a}",
+	"win8":  "{4a2f28e3-53b9-4441-ba9c-d69d4a4a6e38}",
+	"win81": "{1f676c76-80e1-4239-95bb-83d0f6d0da78}",
+	"win10": "{8e0f7a12-bfb3-4fe8-b9a5-48fd50a15a9a}",
+}
+
+var guidPattern = regexp.MustCompile(`^\{[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\}$`)
+
+// AppManifest is an application manifest, stored as a ResourceManifest
+// resource. It is only supported in PE executables.
+type AppManifest struct {
+	// ID is the resource ID. If zero, CreateProcessManifestResourceID is
+	// used, or IsolationAwareManifestResourceID in DLLs.
+	ID uint16
+
+	ResourceLanguage
+
+	// XML, if non-nil, is stored as-is, and the remaining fields are
+	// ignored. Otherwise, the manifest is generated from them.
+	XML []byte
+
+	// ExecutionLevel is the requestedExecutionLevel: asInvoker,
+	// highestAvailable or requireAdministrator. If empty, the trustInfo
+	// element is omitted.
+	ExecutionLevel string
+	UIAccess       bool
+
+	// DPIAware and DPIAwareness are the values of the dpiAware and
+	// dpiAwareness settings, such as "true/pm" and "PerMonitorV2". Empty
+	// values are omitted.
+	DPIAware     string
+	DPIAwareness string
+
+	// LongPathAware enables the longPathAware setting.
+	LongPathAware bool
+
+	// SupportedOS lists the supportedOS GUIDs. The short names vista, win7,
+	// win8, win81 and win10 may be used in place of GUIDs.
+	SupportedOS []string
+}
+
+func (m *AppManifest) id(library bool) uint16 {
+	if m.ID != 0 {
+		return m.ID
+	}
+	if library {
+		return IsolationAwareManifestResourceID
+	}
+	return CreateProcessManifestResourceID
+}
+
+// Encode returns the XML of the manifest.
+func (m *AppManifest) Encode() ([]byte, error) {
+	if m.XML != nil {
+		return m.XML, nil
+	}
+
+	switch m.ExecutionLevel {
+	case "", "asInvoker", "highestAvailable", "requireAdministrator":
+	default:
+		return nil, fmt.Errorf("invalid execution level %q", m.ExecutionLevel)
+	}
+	guids := []string{}
+	for _, os := range m.SupportedOS {
+		if guid, ok := supportedOSGUIDs[strings.ToLower(os)]; ok {
+			os = guid
+		} else if !guidPattern.MatchString(os) {
+			return nil, fmt.Errorf("invalid supported OS %q", os)
+		}
+		guids = append(guids, os)
+	}
+
+	buf := bytes.Buffer{}
+	line := func(indent int, format string, args ...any) {
+		for i, arg := range args {
+			if s, ok := arg.(string); ok {
+				args[i] = xmlEscape(s)
+			}
+		}
+		buf.WriteString(strings.Repeat("  ", indent))
+		fmt.Fprintf(&buf, format, args...)
+		buf.WriteString("\r\n")
+	}
+	line(0, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`)
+	line(0, `<assembly xmlns="urn:schemas-microsoft-com:asm.v1" manifestVersion="1.0">`)
+	if m.ExecutionLevel != "" {
+		line(1, `<trustInfo xmlns="urn:schemas-microsoft-com:asm.v3">`)
+		line(2, `<security>`)
+		line(3, `<requestedPrivileges>`)
+		line(4, `<requestedExecutionLevel level="%s" uiAccess="%t"></requestedExecutionLevel>`, m.ExecutionLevel, m.UIAccess)
+		line(3, `</requestedPrivileges>`)
+		line(2, `</security>`)
+		line(1, `</trustInfo>`)
+	}
+	if len(guids) > 0 {
+		line(1, `<compatibility xmlns="urn:schemas-microsoft-com:compatibility.v1">`)
+		line(2, `<application>`)
+		for _, guid := range guids {
+			line(3, `<supportedOS Id="%s"/>`, guid)
+		}
+		line(2, `</application>`)
+		line(1, `</compatibility>`)
+	}
+	if m.DPIAware != "" || m.DPIAwareness != "" || m.LongPathAware {
+		line(1, `<application xmlns="urn:schemas-microsoft-com:asm.v3">`)
+		line(2, `<windowsSettings>`)
+		if m.DPIAware != "" {
+			line(3, `<dpiAware xmlns="http://schemas.microsoft.com/SMI/2005/WindowsSettings">%s</dpiAware>`, m.DPIAware)
+		}
+		if m.DPIAwareness != "" {
+			line(3, `<dpiAwareness xmlns="http://schemas.microsoft.com/SMI/2016/WindowsSettings">%s</dpiAwareness>`, m.DPIAwareness)
+		}
+		if m.LongPathAware {
+			line(3, `<longPathAware xmlns="http://schemas.microsoft.com/SMI/2016/WindowsSettings">true</longPathAware>`)
+		}
+		line(2, `</windowsSettings>`)
+		line(1, `</application>`)
+	}
+	line(0, `</assembly>`)
+	return buf.Bytes(), nil
+}
+
+func xmlEscape(s string) string {
+	buf := strings.Builder{}
+	must(xml.EscapeText(&buf, []byte(s)), "escaping %q", s)
+	return buf.String()
+}
@@ -0,0 +1,64 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestAppManifestEncode(t *testing.T) {
+	m := &AppManifest{
+		ExecutionLevel: "requireAdministrator",
+		DPIAware:       "true",
+		SupportedOS:    []string{"win10", "{e2011457-1546-43c5-a5fe-008deee3d3f0}"},
+	}
+	data, err := m.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest struct {
+		Level struct {
+			Level    string `xml:"level,attr"`
+			UIAccess bool   `xml:"uiAccess,attr"`
+		} `xml:"trustInfo>security>requestedPrivileges>requestedExecutionLevel"`
+		SupportedOS []struct {
+			ID string `xml:"Id,attr"`
+		} `xml:"compatibility>application>supportedOS"`
+		DPIAware string `xml:"application>windowsSettings>dpiAware"`
+	}
+	if err := xml.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("%v\n%s", err, data)
+	}
+	if manifest.Level.Level != "requireAdministrator" || manifest.Level.UIAccess {
+		t.Errorf("requested execution level %+v", manifest.Level)
+	}
+	if len(manifest.SupportedOS) != 2 || manifest.SupportedOS[0].ID != "{8e0f7a12-bfb3-4fe8-b9a5-48fd50a15a9a}" || manifest.SupportedOS[1].ID != m.SupportedOS[1] {
+		t.Errorf("supported OS %+v", manifest.SupportedOS)
+	}
+	if manifest.DPIAware != "true" {
+		t.Errorf("dpiAware %q", manifest.DPIAware)
+	}
+	if strings.Contains(string(data), "longPathAware") {
+		t.Error("longPathAware is set")
+	}
+}
+
+func TestAppManifestEncodeErrors(t *testing.T) {
+	for _, m := range []*AppManifest{
+		{ExecutionLevel: "asAdministrator"},
+		{SupportedOS: []string{"win95"}},
+	} {
+		if _, err := m.Encode(); err == nil {
+			t.Errorf("Encode of %+v succeeded", m)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"image"
+)
+
+// Bitmap is an image stored as a ResourceBitmap resource, which holds a
+// packed DIB without a file header.
+type Bitmap struct {
+	Name ResourceName
+
+	ResourceLanguage
+
+	DIB *DIB
+}
+
+// NewBitmap converts img into a plain bitmap DIB. The bitmap uses
+// DefaultLanguage.
+func NewBitmap(name ResourceName, img image.Image, nbit int) (*Bitmap, error) {
+	dib, err := NewDIB(img, nil, nbit)
+	if err != nil {
+		return nil, err
+	}
+	return &Bitmap{Name: normalizeResourceName(name), ResourceLanguage: ResourceLanguage{Language: DefaultLanguage}, DIB: dib}, nil
+}
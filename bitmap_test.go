@@ -0,0 +1,90 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBitmapWriteBMP(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 3, 2), color.Palette{color.Black, color.White, color.NRGBA{R: 0xff, A: 0xff}})
+	img.SetColorIndex(0, 0, 2)
+	img.SetColorIndex(2, 1, 1)
+	bitmap, err := NewBitmap(ResourceName{ID: 1}, img, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.Buffer{}
+	if err := bitmap.DIB.WriteBMP(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := unhex(t, `
+		42 4d  4a 00 00 00  00 00  00 00  42 00 00 00
+		28 00 00 00  03 00 00 00  02 00 00 00  01 00  04 00
+		00 00 00 00  00 00 00 00  13 0b 00 00  13 0b 00 00
+		03 00 00 00  03 00 00 00
+		00 00 00 00  ff ff ff 00  00 00 ff 00
+		00 10 00 00
+		20 00 00 00
+	`)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("WriteBMP =\n% x\nwant\n% x", buf.Bytes(), want)
+	}
+	if bitmap.DIB.Size() != len(want)-SizeOfBitmapFileHeader {
+		t.Errorf("Size() = %d, want %d", bitmap.DIB.Size(), len(want)-SizeOfBitmapFileHeader)
+	}
+}
+
+func TestIconImageWriteBMP(t *testing.T) {
+	img := testImage(16, 16, false)
+	dib, err := NewDIB(img, alphaMask{img}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dib.WriteBMP(&bytes.Buffer{}); err == nil {
+		t.Error("WriteBMP of an icon image succeeded")
+	}
+}
+
+func TestNewIconGroupDefaultMask(t *testing.T) {
+	img := testImage(16, 16, false)
+	withMask, err := NewIconGroup(ResourceName{ID: 1}, []IconSource{{Image: img, Mask: alphaMask{img}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"icon", "cursor"} {
+		var group *IconGroup
+		if name == "icon" {
+			group, err = NewIconGroup(ResourceName{ID: 1}, []IconSource{{Image: img}})
+		} else {
+			var cursor *CursorGroup
+			if cursor, err = NewCursorGroup(ResourceName{ID: 1}, []IconSource{{Image: img}}); err == nil {
+				group = &cursor.IconGroup
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, want := bytes.Buffer{}, bytes.Buffer{}
+		group.Images[0].Write(&got)
+		withMask.Images[0].Write(&want)
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("%s image without a mask does not use the alpha channel", name)
+		}
+		var header BitmapInfoHeaderV3
+		if err := binary.Read(&got, binary.LittleEndian, &header); err != nil || header.Height != 32 {
+			t.Errorf("%s image: DIB height %d", name, header.Height)
+		}
+	}
+}
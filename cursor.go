@@ -0,0 +1,134 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+// CursorDirectoryEntry are entries of the directory of .cur files. They are
+// the same as IconDirectoryEntry, except that the planes and bit count are
+// replaced with the hotspot.
+type CursorDirectoryEntry struct {
+	Width       uint8
+	Height      uint8
+	ColorCount  uint8
+	Reserved    uint8
+	HotspotX    uint16
+	HotspotY    uint16
+	ImageSize   uint32
+	ImageOffset uint32
+}
+
+// CursorGroup is a single cursor made up of one or more images. In
+// executables, the group is stored as a ResourceGroupCursor resource that
+// refers to one ResourceCursor resource per image. Cursor images are always
+// DIBs.
+type CursorGroup struct {
+	IconGroup
+
+	// Hotspots holds the hotspot of each image.
+	Hotspots []image.Point
+}
+
+// NewCursorGroup converts each of the sources into a cursor image. The group
+// uses DefaultLanguage.
+func NewCursorGroup(name ResourceName, sources []IconSource) (*CursorGroup, error) {
+	hotspots := []image.Point{}
+	for i, src := range sources {
+		if src.PNG != nil {
+			return nil, fmt.Errorf("image %d: PNG images are not supported in cursors", i+1)
+		}
+		bounds := image.Rect(0, 0, src.Image.Bounds().Dx(), src.Image.Bounds().Dy())
+		if !src.Hotspot.In(bounds) {
+			return nil, fmt.Errorf("image %d: hotspot %v is outside of the image", i+1, src.Hotspot)
+		}
+		hotspots = append(hotspots, src.Hotspot)
+	}
+	group, err := NewIconGroup(name, sources)
+	if err != nil {
+		return nil, err
+	}
+	return &CursorGroup{IconGroup: *group, Hotspots: hotspots}, nil
+}
+
+// checkCursorGroups verifies that the cursor groups can be stored together
+// in a single executable.
+func checkCursorGroups(groups []*CursorGroup) error {
+	iconGroups := []*IconGroup{}
+	for _, group := range groups {
+		if len(group.Hotspots) != len(group.Images) {
+			return errors.New("cursor group has a different number of hotspots and images")
+		}
+		iconGroups = append(iconGroups, &group.IconGroup)
+	}
+	return checkIconGroups(iconGroups, "cursor")
+}
+
+// WriteDirectory writes the group cursor directory resource. The cursor
+// images are expected to be stored as consecutive ResourceCursor resources,
+// starting at firstID.
+func (g *CursorGroup) WriteDirectory(w io.Writer, firstID int) {
+	must(binary.Write(w, binary.LittleEndian, GroupIconDirectory{
+		Type:  2,
+		Count: uint16(len(g.Images)),
+	}), "writing group cursor directory")
+
+	for i, img := range g.Images {
+		must(binary.Write(w, binary.LittleEndian, GroupCursorDirectoryEntry{
+			Width:      uint16(img.Width()),
+			Height:     uint16(img.Height() * 2),
+			NumPlanes:  1,
+			BPP:        uint16(img.BitCount()),
+			ImageSize:  uint32(SizeOfCursorHotspot + img.Size()),
+			ResourceID: uint16(firstID + i),
+		}), "writing group cursor directory entry")
+	}
+}
+
+// WriteImage writes the ResourceCursor resource of the i-th image, which is
+// the image prefixed with its hotspot.
+func (g *CursorGroup) WriteImage(w io.Writer, i int) {
+	must(binary.Write(w, binary.LittleEndian, CursorHotspot{
+		X: uint16(g.Hotspots[i].X),
+		Y: uint16(g.Hotspots[i].Y),
+	}), "writing cursor hotspot")
+	g.Images[i].Write(w)
+}
+
+// WriteCUR writes the group as a standalone .cur file.
+func (g *CursorGroup) WriteCUR(w io.Writer) {
+	must(binary.Write(w, binary.LittleEndian, GroupIconDirectory{
+		Type:  2,
+		Count: uint16(len(g.Images)),
+	}), "writing cursor directory")
+
+	offset := SizeOfGroupIconDirectory + len(g.Images)*SizeOfIconDirectoryEntry
+	for i, img := range g.Images {
+		must(binary.Write(w, binary.LittleEndian, CursorDirectoryEntry{
+			Width:       uint8(img.IconGroupWidth()),
+			Height:      uint8(img.IconGroupHeight()),
+			ColorCount:  uint8(img.ColorCount()),
+			HotspotX:    uint16(g.Hotspots[i].X),
+			HotspotY:    uint16(g.Hotspots[i].Y),
+			ImageSize:   uint32(img.Size()),
+			ImageOffset: uint32(offset),
+		}), "writing cursor directory entry")
+		offset += img.Size()
+	}
+
+	for _, img := range g.Images {
+		img.Write(w)
+	}
+}
@@ -0,0 +1,93 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"testing"
+)
+
+func testCursorGroup(t *testing.T) *CursorGroup {
+	t.Helper()
+	small, large := testImage(16, 16, false), testImage(32, 32, false)
+	group, err := NewCursorGroup(ResourceName{ID: 1}, []IconSource{
+		{Image: small, Mask: alphaMask{small}, Hotspot: image.Pt(3, 4)},
+		{Image: large, Mask: alphaMask{large}, Hotspot: image.Pt(31, 0)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return group
+}
+
+func TestCursorGroupWriteCUR(t *testing.T) {
+	group := testCursorGroup(t)
+	buf := bytes.Buffer{}
+	group.WriteCUR(&buf)
+	entries := parseICO(t, buf.Bytes(), 2)
+	if len(entries) != 2 {
+		t.Fatalf("%d images, want 2", len(entries))
+	}
+	for i, want := range []struct{ size, x, y int }{{16, 3, 4}, {32, 31, 0}} {
+		e := entries[i]
+		if int(e.Width) != want.size || int(e.Height) != want.size {
+			t.Errorf("image %d: %dx%d, want %dx%[4]d", i+1, e.Width, e.Height, want.size)
+		}
+		if int(e.NumPlanes) != want.x || int(e.BitCount) != want.y {
+			t.Errorf("image %d: hotspot (%d, %d), want (%d, %d)", i+1, e.NumPlanes, e.BitCount, want.x, want.y)
+		}
+		if header := dibHeader(t, e.data); int(header.Height) != 2*want.size {
+			t.Errorf("image %d: DIB height %d", i+1, header.Height)
+		}
+	}
+}
+
+func TestCursorGroupResources(t *testing.T) {
+	group := testCursorGroup(t)
+	buf := bytes.Buffer{}
+	group.WriteDirectory(&buf, 7)
+	dir := struct {
+		GroupIconDirectory
+		Entries [2]GroupCursorDirectoryEntry
+	}{}
+	if err := binary.Read(&buf, binary.LittleEndian, &dir); err != nil {
+		t.Fatal(err)
+	}
+	if dir.Type != 2 || dir.Count != 2 {
+		t.Fatalf("directory %+v", dir.GroupIconDirectory)
+	}
+	for i, e := range dir.Entries {
+		size := group.Images[i].Width()
+		if int(e.Width) != size || int(e.Height) != 2*size || e.ResourceID != uint16(7+i) || int(e.ImageSize) != SizeOfCursorHotspot+group.Images[i].Size() {
+			t.Errorf("entry %d: %+v", i+1, e)
+		}
+	}
+
+	buf.Reset()
+	group.WriteImage(&buf, 0)
+	if want := []byte{3, 0, 4, 0}; !bytes.Equal(buf.Bytes()[:4], want) {
+		t.Errorf("image hotspot % x, want % x", buf.Bytes()[:4], want)
+	}
+	if buf.Len() != SizeOfCursorHotspot+group.Images[0].Size() {
+		t.Errorf("image is %d bytes", buf.Len())
+	}
+}
+
+func TestNewCursorGroupErrors(t *testing.T) {
+	img := testImage(16, 16, false)
+	if _, err := NewCursorGroup(ResourceName{ID: 1}, []IconSource{{Image: img, Mask: alphaMask{img}, Hotspot: image.Pt(16, 0)}}); err == nil {
+		t.Error("NewCursorGroup succeeded with the hotspot outside of the image")
+	}
+	if _, err := NewCursorGroup(ResourceName{ID: 1}, []IconSource{{PNG: EncodePNG(img)}}); err == nil {
+		t.Error("NewCursorGroup succeeded with a PNG image")
+	}
+}
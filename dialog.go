@@ -0,0 +1,346 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Enumeration of window styles (incomplete)
+const (
+	WindowStylePopup      = 0x80000000
+	WindowStyleChild      = 0x40000000
+	WindowStyleVisible    = 0x10000000
+	WindowStyleDisabled   = 0x08000000
+	WindowStyleCaption    = 0x00c00000
+	WindowStyleBorder     = 0x00800000
+	WindowStyleVScroll    = 0x00200000
+	WindowStyleHScroll    = 0x00100000
+	WindowStyleSysMenu    = 0x00080000
+	WindowStyleThickFrame = 0x00040000
+	WindowStyleGroup      = 0x00020000
+	WindowStyleTabStop    = 0x00010000
+)
+
+// Enumeration of dialog styles (incomplete)
+const (
+	DialogStyleAbsAlign     = 0x0001
+	DialogStyleSysModal     = 0x0002
+	DialogStyleFixedSys     = 0x0008
+	DialogStyleNoFailCreate = 0x0010
+	DialogStyleSetFont      = 0x0040
+	DialogStyleModalFrame   = 0x0080
+	DialogStyleCenter       = 0x0800
+	DialogStyleShellFont    = DialogStyleSetFont | DialogStyleFixedSys
+)
+
+// Enumeration of control styles (incomplete)
+const (
+	ButtonStylePushButton    = 0x0000
+	ButtonStyleDefPushButton = 0x0001
+	ButtonStyleCheckBox      = 0x0002
+	ButtonStyleAutoCheckBox  = 0x0003
+	ButtonStyleRadioButton   = 0x0004
+	ButtonStyleGroupBox      = 0x0007
+	EditStyleMultiline       = 0x0004
+	EditStyleAutoHScroll     = 0x0080
+	StaticStyleLeft          = 0x0000
+	StaticStyleCenter        = 0x0001
+	StaticStyleIcon          = 0x0003
+)
+
+// Enumeration of predefined dialog control classes. These are stored as
+// ordinals rather than names.
+const (
+	DialogClassButton    = 0x80
+	DialogClassEdit      = 0x81
+	DialogClassStatic    = 0x82
+	DialogClassListBox   = 0x83
+	DialogClassScrollBar = 0x84
+	DialogClassComboBox  = 0x85
+)
+
+// dialogClassNames maps the names of the predefined control classes to
+// their ordinals.
+var dialogClassNames = map[string]uint16{
+	"BUTTON":    DialogClassButton,
+	"EDIT":      DialogClassEdit,
+	"STATIC":    DialogClassStatic,
+	"LISTBOX":   DialogClassListBox,
+	"SCROLLBAR": DialogClassScrollBar,
+	"COMBOBOX":  DialogClassComboBox,
+}
+
+// Dialog is a dialog box template, stored as a ResourceDialog resource.
+type Dialog struct {
+	Name ResourceName
+
+	ResourceLanguage
+
+	// Extended selects the DLGTEMPLATEEX layout, which adds help IDs, font
+	// weights and 32-bit control IDs. It is only supported in PE
+	// executables.
+	Extended bool
+
+	Style   uint32
+	ExStyle uint32
+	HelpID  uint32
+
+	X, Y, Width, Height int16
+
+	// Menu and Class are optional.
+	Menu  ResourceName
+	Class ResourceName
+	Title string
+
+	// Font is optional. If it is set, DialogStyleSetFont is added to the
+	// style.
+	Font *DialogFont
+
+	Controls []DialogControl
+}
+
+// DialogFont is the font of a dialog box.
+type DialogFont struct {
+	PointSize uint16
+	Name      string
+
+	// Weight, Italic and Charset are only stored in extended dialogs.
+	Weight  uint16
+	Italic  bool
+	Charset uint8
+}
+
+// DialogControl is a control within a Dialog.
+type DialogControl struct {
+	// ID is the control ID. It must fit in 16 bits, except in extended
+	// dialogs.
+	ID uint32
+
+	// Class is the window class: one of the predefined class ordinals, such
+	// as DialogClassButton, or a class name.
+	Class ResourceName
+
+	// Text is the window text, or the ID of a resource such as an icon.
+	Text ResourceName
+
+	Style   uint32
+	ExStyle uint32
+	HelpID  uint32
+
+	X, Y, Width, Height int16
+
+	// Data is passed to the control when it is created. Optional.
+	Data []byte
+}
+
+// ParseDialogClass parses a control class: the name of a predefined class,
+// such as BUTTON, stands for its ordinal, and anything else is a class
+// name.
+func ParseDialogClass(s string) ResourceName {
+	if id, ok := dialogClassNames[strings.ToUpper(s)]; ok {
+		return ResourceName{ID: id}
+	}
+	return ResourceName{Name: s}
+}
+
+// Encode encodes the dialog template. If unicode is true, the 32-bit layout
+// used by PE executables is produced, in which strings are UTF-16 and each
+// control is aligned to 32 bits. Otherwise, the packed 16-bit layout used by
+// NE executables is produced.
+func (d *Dialog) Encode(unicode bool) ([]byte, error) {
+	if !unicode {
+		return d.encode16()
+	}
+
+	style := d.Style
+	if d.Font != nil {
+		style |= DialogStyleSetFont
+	}
+	if len(d.Controls) > 0xffff {
+		return nil, fmt.Errorf("too many controls (%d)", len(d.Controls))
+	}
+
+	le := binary.LittleEndian
+	buf := []byte{}
+	if d.Extended {
+		buf = le.AppendUint16(buf, 1)
+		buf = le.AppendUint16(buf, 0xffff)
+		buf = le.AppendUint32(buf, d.HelpID)
+		buf = le.AppendUint32(buf, d.ExStyle)
+		buf = le.AppendUint32(buf, style)
+	} else {
+		buf = le.AppendUint32(buf, style)
+		buf = le.AppendUint32(buf, d.ExStyle)
+	}
+	buf = le.AppendUint16(buf, uint16(len(d.Controls)))
+	buf = appendRect(buf, d.X, d.Y, d.Width, d.Height)
+	buf = appendNameOrOrdinal(buf, d.Menu)
+	buf = appendNameOrOrdinal(buf, d.Class)
+	buf = append(buf, utf16z(d.Title)...)
+	if d.Font != nil {
+		buf = le.AppendUint16(buf, d.Font.PointSize)
+		if d.Extended {
+			buf = le.AppendUint16(buf, d.Font.Weight)
+			buf = append(buf, boolByte(d.Font.Italic), d.Font.Charset)
+		}
+		buf = append(buf, utf16z(d.Font.Name)...)
+	}
+
+	for i, c := range d.Controls {
+		buf = pad32(buf)
+		if d.Extended {
+			buf = le.AppendUint32(buf, c.HelpID)
+			buf = le.AppendUint32(buf, c.ExStyle)
+			buf = le.AppendUint32(buf, c.Style)
+			buf = appendRect(buf, c.X, c.Y, c.Width, c.Height)
+			buf = le.AppendUint32(buf, c.ID)
+		} else {
+			if c.ID > 0xffff {
+				return nil, fmt.Errorf("control %d: ID %d does not fit in 16 bits", i+1, c.ID)
+			}
+			buf = le.AppendUint32(buf, c.Style)
+			buf = le.AppendUint32(buf, c.ExStyle)
+			buf = appendRect(buf, c.X, c.Y, c.Width, c.Height)
+			buf = le.AppendUint16(buf, uint16(c.ID))
+		}
+		buf = appendNameOrOrdinal(buf, c.Class)
+		buf = appendNameOrOrdinal(buf, c.Text)
+
+		// In DLGTEMPLATE, the size of the creation data includes the size
+		// field itself; in DLGTEMPLATEEX, it does not.
+		switch {
+		case len(c.Data) == 0:
+			buf = le.AppendUint16(buf, 0)
+		case d.Extended:
+			buf = le.AppendUint16(buf, uint16(len(c.Data)))
+		default:
+			buf = le.AppendUint16(buf, uint16(len(c.Data)+2))
+		}
+		buf = append(buf, c.Data...)
+	}
+	return buf, nil
+}
+
+// encode16 encodes the dialog template in the 16-bit layout, which has no
+// extended styles and uses ANSI strings.
+func (d *Dialog) encode16() ([]byte, error) {
+	if d.Extended {
+		return nil, errors.New("extended dialogs are not supported in NE executables")
+	}
+	if d.ExStyle != 0 {
+		return nil, errors.New("extended styles are not supported in NE executables")
+	}
+	if len(d.Controls) > 0xff {
+		return nil, fmt.Errorf("too many controls (%d)", len(d.Controls))
+	}
+	if !d.Class.IsName() && d.Class.ID != 0 {
+		return nil, errors.New("class ordinals are not supported in NE executables")
+	}
+	style := d.Style
+	if d.Font != nil {
+		style |= DialogStyleSetFont
+	}
+
+	le := binary.LittleEndian
+	codepage := d.codepage()
+	buf := []byte{}
+	buf = le.AppendUint32(buf, style)
+	buf = append(buf, byte(len(d.Controls)))
+	buf = appendRect(buf, d.X, d.Y, d.Width, d.Height)
+	buf, err := appendNameOrOrdinal16(buf, d.Menu, codepage)
+	if err != nil {
+		return nil, err
+	}
+	if buf, err = appendANSIZ(buf, d.Class.Name, codepage); err != nil {
+		return nil, err
+	}
+	if buf, err = appendANSIZ(buf, d.Title, codepage); err != nil {
+		return nil, err
+	}
+	if d.Font != nil {
+		buf = le.AppendUint16(buf, d.Font.PointSize)
+		if buf, err = appendANSIZ(buf, d.Font.Name, codepage); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, c := range d.Controls {
+		if c.ID > 0xffff {
+			return nil, fmt.Errorf("control %d: ID %d does not fit in 16 bits", i+1, c.ID)
+		}
+		if c.ExStyle != 0 {
+			return nil, fmt.Errorf("control %d: extended styles are not supported in NE executables", i+1)
+		}
+		if len(c.Data) > 0xff {
+			return nil, fmt.Errorf("control %d: too much creation data (%d bytes)", i+1, len(c.Data))
+		}
+		buf = appendRect(buf, c.X, c.Y, c.Width, c.Height)
+		buf = le.AppendUint16(buf, uint16(c.ID))
+		buf = le.AppendUint32(buf, c.Style)
+		if c.Class.IsName() {
+			if buf, err = appendANSIZ(buf, c.Class.Name, codepage); err != nil {
+				return nil, fmt.Errorf("control %d: %w", i+1, err)
+			}
+		} else {
+			if c.Class.ID < 0x80 || c.Class.ID > 0xff {
+				return nil, fmt.Errorf("control %d: invalid class ordinal %#x", i+1, c.Class.ID)
+			}
+			buf = append(buf, byte(c.Class.ID))
+		}
+		if buf, err = appendNameOrOrdinal16(buf, c.Text, codepage); err != nil {
+			return nil, fmt.Errorf("control %d: %w", i+1, err)
+		}
+		buf = append(buf, byte(len(c.Data)))
+		buf = append(buf, c.Data...)
+	}
+	return buf, nil
+}
+
+func appendRect(buf []byte, x, y, width, height int16) []byte {
+	for _, v := range []int16{x, y, width, height} {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(v))
+	}
+	return buf
+}
+
+// appendNameOrOrdinal appends a name or ordinal field of the 32-bit layout:
+// 0x0000 for none, 0xFFFF followed by the ordinal, or a UTF-16 string.
+func appendNameOrOrdinal(buf []byte, n ResourceName) []byte {
+	switch {
+	case n.IsName():
+		return append(buf, utf16z(n.Name)...)
+	case n.ID != 0:
+		buf = binary.LittleEndian.AppendUint16(buf, 0xffff)
+		return binary.LittleEndian.AppendUint16(buf, n.ID)
+	}
+	return binary.LittleEndian.AppendUint16(buf, 0)
+}
+
+// appendNameOrOrdinal16 appends a name or ordinal field of the 16-bit
+// layout: an empty string for none, 0xFF followed by the ordinal, or an ANSI
+// string in codepage.
+func appendNameOrOrdinal16(buf []byte, n ResourceName, codepage uint32) ([]byte, error) {
+	if !n.IsName() && n.ID != 0 {
+		buf = append(buf, 0xff)
+		return binary.LittleEndian.AppendUint16(buf, n.ID), nil
+	}
+	return appendANSIZ(buf, n.Name, codepage)
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func testDialog() *Dialog {
+	return &Dialog{
+		Style:  WindowStylePopup | WindowStyleCaption,
+		Width:  100,
+		Height: 50,
+		Title:  "A",
+		Font:   &DialogFont{PointSize: 8, Name: "B"},
+		Controls: []DialogControl{{
+			ID:     1,
+			Class:  ResourceName{ID: DialogClassButton},
+			Text:   ResourceName{Name: "OK"},
+			Style:  WindowStyleChild | WindowStyleVisible,
+			X:      10,
+			Y:      20,
+			Width:  30,
+			Height: 14,
+		}},
+	}
+}
+
+func TestDialogEncode(t *testing.T) {
+	want := unhex(t, `
+		40 00 c0 80  00 00 00 00  01 00
+		00 00 00 00 64 00 32 00
+		00 00  00 00  41 00 00 00
+		08 00  42 00 00 00
+		00 00 00 50  00 00 00 00
+		0a 00 14 00 1e 00 0e 00  01 00
+		ff ff 80 00  4f 00 4b 00 00 00  00 00
+	`)
+	got, err := testDialog().Encode(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Encode(true) =\n%s\nwant\n%s", hex.Dump(got), hex.Dump(want))
+	}
+}
+
+func TestDialogEncode16(t *testing.T) {
+	want := unhex(t, `
+		40 00 c0 80  01
+		00 00 00 00 64 00 32 00
+		00  00  41 00
+		08 00  42 00
+		0a 00 14 00 1e 00 0e 00  01 00  00 00 00 50
+		80  4f 4b 00  00
+	`)
+	got, err := testDialog().Encode(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Encode(false) =\n%s\nwant\n%s", hex.Dump(got), hex.Dump(want))
+	}
+}
+
+func TestDialogEncode16Extended(t *testing.T) {
+	d := testDialog()
+	d.Extended = true
+	if _, err := d.Encode(false); err == nil {
+		t.Error("Encode(false) of an extended dialog succeeded")
+	}
+}
@@ -11,6 +11,7 @@ package main
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -33,6 +34,18 @@ type BitmapInfoHeaderV3 struct {
 
 const SizeOfBitmapInfoHeaderV3 = 40
 
+// BitmapFileHeader is the header of .bmp files. It is followed by a packed
+// DIB.
+type BitmapFileHeader struct {
+	Type      uint16 // "BM"
+	Size      uint32
+	Reserved1 uint16
+	Reserved2 uint16
+	OffBits   uint32
+}
+
+const SizeOfBitmapFileHeader = 14
+
 type DIB struct {
 	width, height  int
 	bpp, numColors int
@@ -48,6 +61,10 @@ type DIB struct {
 	image, mask image.Image
 }
 
+// NewDIB converts img into a packed DIB with the given bit depth, or the
+// depth of the image if nbit is zero. If mask is non-nil, the DIB is an icon
+// image: its height is doubled and it is followed by the AND mask. If mask is
+// nil, the DIB is a plain bitmap.
 func NewDIB(img image.Image, mask image.Image, nbit int) (*DIB, error) {
 	w := DIB{image: img, mask: mask}
 	w.width = img.Bounds().Dx()
@@ -80,15 +97,18 @@ func NewDIB(img image.Image, mask image.Image, nbit int) (*DIB, error) {
 			w.bpp = 16
 		}
 	}
-	if w.bpp != nbit {
+	if nbit != 0 && w.bpp != nbit {
 		return nil, fmt.Errorf("expected %d bits, got %d", nbit, w.bpp)
 	}
 
 	w.headerSize = SizeOfBitmapInfoHeaderV3
 	w.paletteSize = 4 * w.numColors
 	w.scanlineStride = bppstride(w.width, w.bpp)
-	w.maskScanlineStride = bppstride(w.width, 1)
-	w.size = w.headerSize + w.paletteSize + w.scanlineStride*w.height + w.maskScanlineStride*w.height
+	w.size = w.headerSize + w.paletteSize + w.scanlineStride*w.height
+	if mask != nil {
+		w.maskScanlineStride = bppstride(w.width, 1)
+		w.size += w.maskScanlineStride * w.height
+	}
 	return &w, nil
 }
 
@@ -106,15 +126,41 @@ func (d *DIB) IconGroupHeight() int {
 	return d.height
 }
 
+func (d *DIB) Width() int {
+	return d.width
+}
+
+func (d *DIB) Height() int {
+	return d.height
+}
+
+func (d *DIB) ColorCount() int {
+	return d.numColors
+}
+
+func (d *DIB) BitCount() int {
+	return d.bpp
+}
+
+func (d *DIB) Size() int {
+	return d.size
+}
+
 func (d *DIB) Write(w io.Writer) {
 	iconScanline := make([]byte, d.scanlineStride)
 	iconMaskScanline := make([]byte, d.maskScanlineStride)
 
+	// Icon images store the height of the image and mask combined.
+	height := d.height
+	if d.mask != nil {
+		height *= 2
+	}
+
 	// Icon data
 	must(binary.Write(w, binary.LittleEndian, BitmapInfoHeaderV3{
 		Size:            uint32(SizeOfBitmapInfoHeaderV3),
 		Width:           int32(d.width),
-		Height:          int32(d.height * 2),
+		Height:          int32(height),
 		Planes:          1,
 		BPP:             int16(d.bpp),
 		Compression:     0,
@@ -226,6 +272,9 @@ func (d *DIB) Write(w io.Writer) {
 		}
 	}
 
+	if d.mask == nil {
+		return
+	}
 	for y := d.height - 1; y >= 0; y-- {
 		i, x := 0, 0
 		for ; i < d.width/8; i++ {
@@ -252,6 +301,22 @@ func (d *DIB) Write(w io.Writer) {
 	}
 }
 
+// WriteBMP writes a plain bitmap DIB as a standalone .bmp file. Icon images,
+// which have an AND mask and a doubled height, cannot be written as .bmp
+// files.
+func (d *DIB) WriteBMP(w io.Writer) error {
+	if d.mask != nil {
+		return errors.New("icon images cannot be written as .bmp files")
+	}
+	must(binary.Write(w, binary.LittleEndian, BitmapFileHeader{
+		Type:    'B' | 'M'<<8,
+		Size:    uint32(SizeOfBitmapFileHeader + d.size),
+		OffBits: uint32(SizeOfBitmapFileHeader + d.headerSize + d.paletteSize),
+	}), "writing bitmap file header")
+	d.Write(w)
+	return nil
+}
+
 func bppstride(w, bpp int) int {
 	return (((w * bpp) + 31) &^ 31) / 8
 }
@@ -263,3 +328,21 @@ func threshold(c color.Color) uint8 {
 	}
 	return 0
 }
+
+// alphaMask adapts an image into an icon mask, treating pixels that are less
+// than half opaque as transparent.
+type alphaMask struct {
+	image.Image
+}
+
+func (m alphaMask) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+func (m alphaMask) At(x, y int) color.Color {
+	_, _, _, a := m.Image.At(x, y).RGBA()
+	if a < 0x8000 {
+		return color.White
+	}
+	return color.Black
+}
@@ -0,0 +1,186 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Export is a function exported by a PE executable. Exports that are not
+// forwarded all point to the same stub function, which returns zero.
+type Export struct {
+	// Name is the name of the export. If empty, the function is only
+	// exported by ordinal.
+	Name string
+
+	// Ordinal is the ordinal of the export. If zero, the lowest unused
+	// ordinal is assigned.
+	Ordinal uint16
+
+	// Forwarder, if not empty, forwards the export to a function of another
+	// DLL, given as DLL.Function or DLL.#Ordinal, such as NTDLL.RtlFoo.
+	Forwarder string
+}
+
+// ParseExport parses an export: a name or an ordinal prefixed with "#",
+// optionally followed by "@ordinal" and "=forwarder", such as "Foo",
+// "Foo@5", "#7" or "Bar=NTDLL.RtlBar".
+func ParseExport(s string) (Export, error) {
+	export := Export{}
+	s, export.Forwarder, _ = strings.Cut(s, "=")
+	if ordinal, ok := strings.CutPrefix(s, "#"); ok {
+		s = "@" + ordinal
+	}
+	s, ordinal, ok := strings.Cut(s, "@")
+	export.Name = s
+	if ok {
+		n, err := strconv.ParseUint(ordinal, 0, 16)
+		if err != nil || n == 0 {
+			return Export{}, fmt.Errorf("invalid ordinal %q", ordinal)
+		}
+		export.Ordinal = uint16(n)
+	}
+	return export, nil
+}
+
+// hasStub reports whether any of exports needs the stub function.
+func hasStub(exports []Export) bool {
+	for _, export := range exports {
+		if export.Forwarder == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// exportOrdinals returns the ordinal of each export, assigning the lowest
+// unused ordinals to those that do not have one.
+func exportOrdinals(exports []Export) ([]uint16, error) {
+	ordinals := make([]uint16, len(exports))
+	used := map[uint16]bool{}
+	names := map[string]bool{}
+	for i, export := range exports {
+		if export.Name == "" && export.Ordinal == 0 {
+			return nil, fmt.Errorf("export %d has neither a name nor an ordinal", i+1)
+		}
+		if export.Name != "" {
+			if names[export.Name] {
+				return nil, fmt.Errorf("duplicate export %q", export.Name)
+			}
+			names[export.Name] = true
+		}
+		if export.Forwarder != "" && !strings.Contains(export.Forwarder, ".") {
+			return nil, fmt.Errorf("export %d: invalid forwarder %q", i+1, export.Forwarder)
+		}
+		if export.Ordinal != 0 {
+			if used[export.Ordinal] {
+				return nil, fmt.Errorf("duplicate export ordinal %d", export.Ordinal)
+			}
+			used[export.Ordinal] = true
+			ordinals[i] = export.Ordinal
+		}
+	}
+	next := uint16(1)
+	for i := range exports {
+		if ordinals[i] != 0 {
+			continue
+		}
+		for used[next] {
+			next++
+		}
+		ordinals[i], used[next] = next, true
+	}
+	return ordinals, nil
+}
+
+// encodeExports encodes the contents of an .edata section at rva: the export
+// directory, the export address table, the name pointer table, the ordinal
+// table, then the DLL name, export names and forwarder strings. The address
+// table covers every ordinal from the lowest to the highest; gaps are zero.
+// Forwarded exports point to their forwarder string, and the others to
+// stubRVA.
+func encodeExports(dllName string, exports []Export, rva, stubRVA uint32) ([]byte, error) {
+	if dllName == "" {
+		return nil, errors.New("exports require a module name")
+	}
+	ordinals, err := exportOrdinals(exports)
+	if err != nil {
+		return nil, err
+	}
+	base, last := ordinals[0], ordinals[0]
+	named := []int{}
+	for i, ordinal := range ordinals {
+		if ordinal < base {
+			base = ordinal
+		}
+		if ordinal > last {
+			last = ordinal
+		}
+		if exports[i].Name != "" {
+			named = append(named, i)
+		}
+	}
+	// The loader looks up names with a binary search.
+	sort.Slice(named, func(i, j int) bool { return exports[named[i]].Name < exports[named[j]].Name })
+
+	le := binary.LittleEndian
+	numFunctions := int(last-base) + 1
+	functionsOffset := SizeOfImageExportDirectory
+	namesOffset := functionsOffset + numFunctions*4
+	nameOrdinalsOffset := namesOffset + len(named)*4
+	stringsOffset := nameOrdinalsOffset + len(named)*2
+
+	strs, err := ansiz(dllName, DefaultCodepage)
+	if err != nil {
+		return nil, fmt.Errorf("DLL name: %w", err)
+	}
+	functions := make([]byte, numFunctions*4)
+	for i, export := range exports {
+		address := stubRVA
+		if export.Forwarder != "" {
+			address = rva + uint32(stringsOffset+len(strs))
+			if strs, err = appendANSIZ(strs, export.Forwarder, DefaultCodepage); err != nil {
+				return nil, fmt.Errorf("export %d: %w", i+1, err)
+			}
+		}
+		le.PutUint32(functions[int(ordinals[i]-base)*4:], address)
+	}
+	names := []byte{}
+	nameOrdinals := []byte{}
+	for _, i := range named {
+		names = le.AppendUint32(names, rva+uint32(stringsOffset+len(strs)))
+		nameOrdinals = le.AppendUint16(nameOrdinals, ordinals[i]-base)
+		if strs, err = appendANSIZ(strs, exports[i].Name, DefaultCodepage); err != nil {
+			return nil, fmt.Errorf("export %d: %w", i+1, err)
+		}
+	}
+
+	buf := bytes.Buffer{}
+	must(binary.Write(&buf, le, ImageExportDirectory{
+		Name:                  rva + uint32(stringsOffset),
+		Base:                  uint32(base),
+		NumberOfFunctions:     uint32(numFunctions),
+		NumberOfNames:         uint32(len(named)),
+		AddressOfFunctions:    rva + uint32(functionsOffset),
+		AddressOfNames:        rva + uint32(namesOffset),
+		AddressOfNameOrdinals: rva + uint32(nameOrdinalsOffset),
+	}), "writing export directory")
+	buf.Write(functions)
+	buf.Write(names)
+	buf.Write(nameOrdinals)
+	buf.Write(strs)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,337 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+)
+
+// FontHeader is the header of a version 2.0 raster font (.fnt), which is
+// stored as a ResourceFont resource.
+type FontHeader struct {
+	Version         uint16
+	Size            uint32
+	Copyright       [60]byte
+	Type            uint16
+	Points          uint16
+	VertRes         uint16
+	HorizRes        uint16
+	Ascent          uint16
+	InternalLeading uint16
+	ExternalLeading uint16
+	Italic          uint8
+	Underline       uint8
+	StrikeOut       uint8
+	Weight          uint16
+	CharSet         uint8
+	PixWidth        uint16
+	PixHeight       uint16
+	PitchAndFamily  uint8
+	AvgWidth        uint16
+	MaxWidth        uint16
+	FirstChar       uint8
+	LastChar        uint8
+	DefaultChar     uint8
+	BreakChar       uint8
+	WidthBytes      uint16
+	Device          uint32
+	Face            uint32
+	BitsPointer     uint32
+	BitsOffset      uint32
+	Reserved        uint8
+}
+
+const (
+	SizeOfFontHeader = 118
+
+	// SizeOfFontDirEntryHeader is the size of the part of FontHeader that is
+	// copied into each font directory entry: everything up to and including
+	// BitsPointer, which is reserved there.
+	SizeOfFontDirEntryHeader = 113
+
+	// SizeOfFontCharEntry is the size of an entry of the character table of
+	// version 2.0 fonts: a 16-bit width and a 16-bit offset.
+	SizeOfFontCharEntry = 4
+)
+
+// Enumeration of font families, stored in the high nibble of
+// PitchAndFamily.
+const (
+	FontFamilyDontCare   = 0x00
+	FontFamilyRoman      = 0x10
+	FontFamilySwiss      = 0x20
+	FontFamilyModern     = 0x30
+	FontFamilyScript     = 0x40
+	FontFamilyDecorative = 0x50
+
+	// fontVariablePitch is the low bit of PitchAndFamily. Note that it is
+	// the opposite of the LOGFONT convention.
+	fontVariablePitch = 0x01
+)
+
+// FontResolution is the resolution, in dots per inch, that fonts are
+// designed for.
+const FontResolution = 96
+
+// Font is a raster font, stored as a ResourceFont resource. Executables with
+// fonts also have a ResourceFontDir resource, named FONTDIR, describing
+// them; NE executables holding only fonts are .fon files.
+type Font struct {
+	// Name must be an integer ID, which the font directory refers to.
+	Name ResourceName
+
+	ResourceLanguage
+
+	FaceName  string
+	Copyright string
+
+	// Points is the nominal size. If zero, it is derived from the height of
+	// the glyphs at FontResolution.
+	Points uint16
+
+	// Ascent is the distance from the top of the glyphs to the baseline. If
+	// zero, it is the height of the glyphs.
+	Ascent uint16
+
+	// Weight defaults to 400 (normal).
+	Weight    uint16
+	Italic    bool
+	Underline bool
+	StrikeOut bool
+	Charset   uint8
+	Family    uint8
+
+	// FirstChar is the character of the first glyph; the others follow
+	// consecutively. DefaultChar and BreakChar must be within the range of
+	// the glyphs, and default to FirstChar.
+	FirstChar   byte
+	DefaultChar byte
+	BreakChar   byte
+
+	// Glyphs all have the same height. A pixel is set if it is opaque and
+	// dark. The font has a fixed pitch if the glyphs all have the same
+	// width.
+	Glyphs []image.Image
+}
+
+// NewFontFromStrip creates a font from a strip of glyphs of equal width,
+// laid out from left to right starting with firstChar. The font uses
+// DefaultLanguage.
+func NewFontFromStrip(name ResourceName, faceName string, img image.Image, firstChar byte, cellWidth int) (*Font, error) {
+	b := img.Bounds()
+	if cellWidth <= 0 || b.Dx()%cellWidth != 0 {
+		return nil, fmt.Errorf("image width %d is not a multiple of the cell width %d", b.Dx(), cellWidth)
+	}
+	sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil, errors.New("unsupported image type")
+	}
+	font := &Font{
+		Name:             name,
+		ResourceLanguage: ResourceLanguage{Language: DefaultLanguage},
+		FaceName:         faceName,
+		FirstChar:        firstChar,
+		DefaultChar:      firstChar,
+		BreakChar:        firstChar,
+	}
+	for x := b.Min.X; x < b.Max.X; x += cellWidth {
+		font.Glyphs = append(font.Glyphs, sub.SubImage(image.Rect(x, b.Min.Y, x+cellWidth, b.Max.Y)))
+	}
+	return font, nil
+}
+
+func (f *Font) lastChar() int {
+	return int(f.FirstChar) + len(f.Glyphs) - 1
+}
+
+// Header returns the font header, except for the offsets and size, which
+// depend on the layout of the file.
+func (f *Font) Header() (FontHeader, error) {
+	header := FontHeader{}
+	if len(f.Glyphs) == 0 {
+		return header, errors.New("font has no glyphs")
+	}
+	if f.lastChar() > 0xff {
+		return header, fmt.Errorf("too many glyphs (%d) starting from %d", len(f.Glyphs), f.FirstChar)
+	}
+	for _, c := range []byte{f.DefaultChar, f.BreakChar} {
+		if c < f.FirstChar || int(c) > f.lastChar() {
+			return header, fmt.Errorf("character %d is outside of the font", c)
+		}
+	}
+	if len(f.Copyright) > len(header.Copyright) {
+		return header, fmt.Errorf("copyright is too long (%d bytes)", len(f.Copyright))
+	}
+
+	height := f.Glyphs[0].Bounds().Dy()
+	totalWidth, maxWidth, widthBytes := 0, 0, 0
+	fixed := true
+	for i, glyph := range f.Glyphs {
+		b := glyph.Bounds()
+		if b.Dy() != height {
+			return header, fmt.Errorf("glyph %d: height %d does not match %d", int(f.FirstChar)+i, b.Dy(), height)
+		}
+		totalWidth += b.Dx()
+		if b.Dx() > maxWidth {
+			maxWidth = b.Dx()
+		}
+		widthBytes += (b.Dx() + 7) / 8
+		fixed = fixed && b.Dx() == f.Glyphs[0].Bounds().Dx()
+	}
+
+	header = FontHeader{
+		Version:     0x0200,
+		Points:      f.Points,
+		VertRes:     FontResolution,
+		HorizRes:    FontResolution,
+		Ascent:      f.Ascent,
+		Italic:      boolByte(f.Italic),
+		Underline:   boolByte(f.Underline),
+		StrikeOut:   boolByte(f.StrikeOut),
+		Weight:      f.Weight,
+		CharSet:     f.Charset,
+		PixHeight:   uint16(height),
+		AvgWidth:    uint16((totalWidth + len(f.Glyphs)/2) / len(f.Glyphs)),
+		MaxWidth:    uint16(maxWidth),
+		FirstChar:   f.FirstChar,
+		LastChar:    byte(f.lastChar()),
+		DefaultChar: f.DefaultChar - f.FirstChar,
+		BreakChar:   f.BreakChar - f.FirstChar,
+		WidthBytes:  uint16(align(widthBytes, 2)),
+	}
+	copy(header.Copyright[:], f.Copyright)
+	if header.Points == 0 {
+		header.Points = uint16((height*72 + FontResolution/2) / FontResolution)
+	}
+	if header.Ascent == 0 {
+		header.Ascent = uint16(height)
+	}
+	if header.Weight == 0 {
+		header.Weight = 400
+	}
+	header.PitchAndFamily = f.Family &^ 0x0f
+	if fixed {
+		header.PixWidth = uint16(f.Glyphs[0].Bounds().Dx())
+	} else {
+		header.PitchAndFamily |= fontVariablePitch
+	}
+	return header, nil
+}
+
+// Encode encodes the font as a version 2.0 .fnt file: the header, the
+// character table, the glyph bitmaps and the face name. Glyph bitmaps are
+// stored in columns 8 pixels wide, from top to bottom. The character table
+// has an extra entry after the last character, for a blank glyph.
+func (f *Font) Encode() ([]byte, error) {
+	header, err := f.Header()
+	if err != nil {
+		return nil, err
+	}
+
+	glyphs := append(f.Glyphs[:len(f.Glyphs):len(f.Glyphs)], image.Rect(0, 0, int(header.AvgWidth), int(header.PixHeight)))
+	le := binary.LittleEndian
+	table := []byte{}
+	bits := []byte{}
+	header.BitsOffset = uint32(SizeOfFontHeader + len(glyphs)*SizeOfFontCharEntry)
+	for _, glyph := range glyphs {
+		offset := int(header.BitsOffset) + len(bits)
+		if offset > 0xffff {
+			return nil, errors.New("font is too large for a version 2.0 font")
+		}
+		b := glyph.Bounds()
+		table = le.AppendUint16(table, uint16(b.Dx()))
+		table = le.AppendUint16(table, uint16(offset))
+		for col := 0; col < b.Dx(); col += 8 {
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				v := byte(0)
+				for x := 0; x < 8 && col+x < b.Dx(); x++ {
+					if glyphPixel(glyph, b.Min.X+col+x, y) {
+						v |= 0x80 >> x
+					}
+				}
+				bits = append(bits, v)
+			}
+		}
+	}
+	header.Face = header.BitsOffset + uint32(len(bits))
+	face, err := ansiz(f.FaceName, f.codepage())
+	if err != nil {
+		return nil, fmt.Errorf("face name: %w", err)
+	}
+	header.Size = header.Face + uint32(len(face))
+
+	buf := bytes.Buffer{}
+	must(binary.Write(&buf, le, header), "writing font header")
+	buf.Write(table)
+	buf.Write(bits)
+	buf.Write(face)
+	return buf.Bytes(), nil
+}
+
+// glyphPixel reports whether a pixel of a glyph is set: at least half
+// opaque, and darker than mid-grey. Plain rectangles, used for blank glyphs,
+// are opaque white.
+func glyphPixel(glyph image.Image, x, y int) bool {
+	r, g, b, a := glyph.At(x, y).RGBA()
+	return a >= 0x8000 && (r+g+b)/3 < a/2
+}
+
+// EncodeFontDir encodes the font directory for fonts: the number of fonts,
+// then for each, its ID, the first part of its header, and its device and
+// face names.
+func EncodeFontDir(fonts []*Font) ([]byte, error) {
+	le := binary.LittleEndian
+	buf := le.AppendUint16(nil, uint16(len(fonts)))
+	for _, font := range fonts {
+		if font.Name.IsName() {
+			return nil, fmt.Errorf("font %s: fonts must have integer IDs", font.Name)
+		}
+		data, err := font.Encode()
+		if err != nil {
+			return nil, fmt.Errorf("font %s: %w", font.Name, err)
+		}
+		buf = le.AppendUint16(buf, font.Name.ID)
+		buf = append(buf, data[:SizeOfFontDirEntryHeader]...)
+		buf = append(buf, 0)
+		if buf, err = appendANSIZ(buf, font.FaceName, font.codepage()); err != nil {
+			return nil, fmt.Errorf("font %s: face name: %w", font.Name, err)
+		}
+	}
+	return buf, nil
+}
+
+// FontResourceDescription returns the module description conventionally
+// given to .fon files, such as "FONTRES 100,96,96 : Mock Sans 8, 10". The
+// numbers are the aspect ratio and resolution the fonts are designed for.
+func FontResourceDescription(fonts []*Font) string {
+	faces := []string{}
+	sizes := map[string][]string{}
+	for _, font := range fonts {
+		header, err := font.Header()
+		if err != nil {
+			continue
+		}
+		if _, ok := sizes[font.FaceName]; !ok {
+			faces = append(faces, font.FaceName)
+		}
+		sizes[font.FaceName] = append(sizes[font.FaceName], fmt.Sprint(header.Points))
+	}
+	for i, face := range faces {
+		faces[i] = face + " " + strings.Join(sizes[face], ", ")
+	}
+	return fmt.Sprintf("FONTRES 100,%d,%d : %s", FontResolution, FontResolution, strings.Join(faces, "; "))
+}
@@ -0,0 +1,123 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testFont returns a font with two 3×2 glyphs: 'A', with its top-left pixel
+// set, and 'B', with its bottom-right pixel set.
+func testFont(t *testing.T) *Font {
+	t.Helper()
+	strip := image.NewGray(image.Rect(0, 0, 6, 2))
+	for i := range strip.Pix {
+		strip.Pix[i] = 0xff
+	}
+	strip.SetGray(0, 0, color.Gray{})
+	strip.SetGray(5, 1, color.Gray{})
+	font, err := NewFontFromStrip(ResourceName{ID: 1}, "Mock", strip, 'A', 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return font
+}
+
+func TestFontEncode(t *testing.T) {
+	data, err := testFont(t).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := FontHeader{}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	want := FontHeader{
+		Version:    0x0200,
+		Size:       SizeOfFontHeader + 3*SizeOfFontCharEntry + 6 + 5,
+		Points:     2,
+		VertRes:    FontResolution,
+		HorizRes:   FontResolution,
+		Ascent:     2,
+		Weight:     400,
+		PixWidth:   3,
+		PixHeight:  2,
+		AvgWidth:   3,
+		MaxWidth:   3,
+		FirstChar:  'A',
+		LastChar:   'B',
+		WidthBytes: 2,
+		Face:       SizeOfFontHeader + 3*SizeOfFontCharEntry + 6,
+		BitsOffset: SizeOfFontHeader + 3*SizeOfFontCharEntry,
+	}
+	if header != want {
+		t.Errorf("header %+v, want %+v", header, want)
+	}
+	if int(header.Size) != len(data) {
+		t.Errorf("size %d, want %d", header.Size, len(data))
+	}
+	rest := unhex(t, `
+		03 00 82 00  03 00 84 00  03 00 86 00
+		80 00  00 20  00 00
+		4d 6f 63 6b 00
+	`)
+	if !bytes.Equal(data[SizeOfFontHeader:], rest) {
+		t.Errorf("glyphs and face name % x, want % x", data[SizeOfFontHeader:], rest)
+	}
+}
+
+func TestEncodeFontDir(t *testing.T) {
+	font := testFont(t)
+	data, err := font.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := EncodeFontDir([]*Font{font})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{1, 0, 1, 0}, data[:SizeOfFontDirEntryHeader]...)
+	want = append(want, 0, 'M', 'o', 'c', 'k', 0)
+	if !bytes.Equal(dir, want) {
+		t.Errorf("EncodeFontDir =\n% x\nwant\n% x", dir, want)
+	}
+
+	font.Name = ResourceName{Name: "MOCK"}
+	if _, err := EncodeFontDir([]*Font{font}); err == nil {
+		t.Error("EncodeFontDir succeeded with a named font")
+	}
+}
+
+func TestFontResourceDescription(t *testing.T) {
+	small, large := testFont(t), testFont(t)
+	large.Points = 10
+	if got, want := FontResourceDescription([]*Font{small, large}), "FONTRES 100,96,96 : Mock 2, 10"; got != want {
+		t.Errorf("FontResourceDescription = %q, want %q", got, want)
+	}
+}
+
+func TestFontHeaderErrors(t *testing.T) {
+	for _, modify := range []func(f *Font){
+		func(f *Font) { f.Glyphs = nil },
+		func(f *Font) { f.DefaultChar = 'C' },
+		func(f *Font) { f.FirstChar = 0xff },
+		func(f *Font) { f.Glyphs[1] = image.Rect(0, 0, 3, 3) },
+	} {
+		font := testFont(t)
+		modify(font)
+		if _, err := font.Header(); err == nil {
+			t.Errorf("Header of %+v succeeded", font)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+// IconDirectoryEntry are entries of the GroupIconDirectory when stored on-disk.
+type IconDirectoryEntry struct {
+	Width       uint8
+	Height      uint8
+	ColorCount  uint8
+	Reserved    uint8
+	NumPlanes   uint16
+	BitCount    uint16
+	ImageSize   uint32
+	ImageOffset uint32
+}
+
+const SizeOfIconDirectoryEntry = 16
+
+// IconImage is a single image within an icon group.
+type IconImage interface {
+	// IconGroupWidth and IconGroupHeight return the dimensions of the image
+	// as stored in icon directories, where 0 means 256 or more.
+	IconGroupWidth() int
+	IconGroupHeight() int
+
+	// Width and Height return the actual dimensions of the image.
+	Width() int
+	Height() int
+
+	// ColorCount returns the number of palette entries, or 0 if there is no
+	// palette.
+	ColorCount() int
+
+	// BitCount returns the bit depth of the image.
+	BitCount() int
+
+	// Size returns the size of the encoded image.
+	Size() int
+
+	// Write writes the encoded image.
+	Write(w io.Writer)
+}
+
+// IconSource is an image that is to be converted into an icon image.
+type IconSource struct {
+	Image image.Image
+
+	// Mask is the AND mask of the image. If nil, it is derived from the
+	// alpha channel of Image.
+	Mask image.Image
+
+	BPP int
+
+	// PNG, if non-nil, is a PNG stream to store as-is instead of converting
+	// Image and Mask to a DIB.
+	PNG []byte
+
+	// Hotspot is the hotspot of cursor images. It is ignored for icons.
+	Hotspot image.Point
+}
+
+// IconGroup is a single icon made up of one or more images, usually one for
+// each size and bit depth. In executables, the group is stored as a
+// ResourceGroupIcon resource that refers to one ResourceIcon resource per
+// image.
+type IconGroup struct {
+	Name ResourceName
+
+	// ResourceLanguage applies to the group and its images.
+	ResourceLanguage
+
+	Images []IconImage
+}
+
+// NewIconGroup converts each of the sources into an icon image. The group
+// uses DefaultLanguage.
+func NewIconGroup(name ResourceName, sources []IconSource) (*IconGroup, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("icon group has no images")
+	}
+	if len(sources) > 0xffff {
+		return nil, fmt.Errorf("too many images in icon group (%d)", len(sources))
+	}
+	group := &IconGroup{Name: normalizeResourceName(name), ResourceLanguage: ResourceLanguage{Language: DefaultLanguage}}
+	for i, src := range sources {
+		var img IconImage
+		var err error
+		if src.PNG != nil {
+			img, err = NewPNGIconImage(src.PNG, src.BPP)
+		} else {
+			mask := src.Mask
+			if mask == nil {
+				mask = alphaMask{src.Image}
+			}
+			img, err = NewDIB(src.Image, mask, src.BPP)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", i+1, err)
+		}
+		group.Images = append(group.Images, img)
+	}
+	return group, nil
+}
+
+// checkIconGroups verifies that the icon groups can be stored together in a
+// single executable. kind is used in error messages.
+func checkIconGroups(groups []*IconGroup, kind string) error {
+	type key struct {
+		name     ResourceName
+		language uint16
+	}
+	numImages := 0
+	seen := map[key]bool{}
+	for _, group := range groups {
+		k := key{group.Name, group.Language}
+		if seen[k] {
+			return fmt.Errorf("duplicate %s group %s (language %#04x)", kind, group.Name, group.Language)
+		}
+		seen[k] = true
+		numImages += len(group.Images)
+	}
+	if numImages > 0xffff {
+		return fmt.Errorf("too many %s images (%d)", kind, numImages)
+	}
+	return nil
+}
+
+// DirectorySize returns the size of the group icon directory resource.
+func (g *IconGroup) DirectorySize() int {
+	return SizeOfGroupIconDirectory + len(g.Images)*SizeOfGroupIconDirectoryEntry
+}
+
+// ImageSize returns the combined size of all of the images in the group.
+func (g *IconGroup) ImageSize() int {
+	size := 0
+	for _, img := range g.Images {
+		size += img.Size()
+	}
+	return size
+}
+
+// WriteDirectory writes the group icon directory resource. The icon images
+// are expected to be stored as consecutive ResourceIcon resources, starting
+// at firstID.
+func (g *IconGroup) WriteDirectory(w io.Writer, firstID int) {
+	must(binary.Write(w, binary.LittleEndian, GroupIconDirectory{
+		Type:  1,
+		Count: uint16(len(g.Images)),
+	}), "writing group icon directory")
+
+	for i, img := range g.Images {
+		must(binary.Write(w, binary.LittleEndian, GroupIconDirectoryEntry{
+			Width:      uint8(img.IconGroupWidth()),
+			Height:     uint8(img.IconGroupHeight()),
+			ColorCount: uint8(img.ColorCount()),
+			NumPlanes:  1,
+			BPP:        uint16(img.BitCount()),
+			ImageSize:  uint32(img.Size()),
+			ResourceID: uint16(firstID + i),
+		}), "writing group icon directory entry")
+	}
+}
+
+// WriteICO writes the group as a standalone .ico file.
+func (g *IconGroup) WriteICO(w io.Writer) {
+	must(binary.Write(w, binary.LittleEndian, GroupIconDirectory{
+		Type:  1,
+		Count: uint16(len(g.Images)),
+	}), "writing icon directory")
+
+	offset := SizeOfGroupIconDirectory + len(g.Images)*SizeOfIconDirectoryEntry
+	for _, img := range g.Images {
+		must(binary.Write(w, binary.LittleEndian, IconDirectoryEntry{
+			Width:       uint8(img.IconGroupWidth()),
+			Height:      uint8(img.IconGroupHeight()),
+			ColorCount:  uint8(img.ColorCount()),
+			NumPlanes:   1,
+			BitCount:    uint16(img.BitCount()),
+			ImageSize:   uint32(img.Size()),
+			ImageOffset: uint32(offset),
+		}), "writing icon directory entry")
+		offset += img.Size()
+	}
+
+	for _, img := range g.Images {
+		img.Write(w)
+	}
+}
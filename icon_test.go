@@ -0,0 +1,174 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// unhex decodes golden bytes written as hex, ignoring whitespace.
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// testImage returns a w×h gradient. Unless opaque is true, its top-left
+// quarter is transparent.
+func testImage(w, h int, opaque bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: 0x80, A: 0xff}
+			if !opaque && x < w/2 && y < h/2 {
+				c.A = 0
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// icoEntry is a decoded entry of the directory of an .ico or .cur file.
+type icoEntry struct {
+	IconDirectoryEntry
+	data []byte
+}
+
+// parseICO decodes the directory of an .ico or .cur file of the given type,
+// checking that the images are stored back to back after it. In .cur files,
+// NumPlanes and BitCount hold the hotspot instead.
+func parseICO(t *testing.T, data []byte, typ uint16) []icoEntry {
+	t.Helper()
+	dir := GroupIconDirectory{}
+	r := bytes.NewReader(data)
+	if err := binary.Read(r, binary.LittleEndian, &dir); err != nil {
+		t.Fatal(err)
+	}
+	if dir.Type != typ {
+		t.Fatalf("directory type %d, want %d", dir.Type, typ)
+	}
+	entries := make([]icoEntry, dir.Count)
+	offset := SizeOfGroupIconDirectory + len(entries)*SizeOfIconDirectoryEntry
+	for i := range entries {
+		e := &entries[i]
+		if err := binary.Read(r, binary.LittleEndian, &e.IconDirectoryEntry); err != nil {
+			t.Fatal(err)
+		}
+		if int(e.ImageOffset) != offset || offset+int(e.ImageSize) > len(data) {
+			t.Fatalf("image %d at %d (%d bytes), want %d", i+1, e.ImageOffset, e.ImageSize, offset)
+		}
+		e.data = data[offset : offset+int(e.ImageSize)]
+		offset += int(e.ImageSize)
+	}
+	if offset != len(data) {
+		t.Fatalf("%d trailing bytes", len(data)-offset)
+	}
+	return entries
+}
+
+// dibHeader decodes the header of a packed DIB.
+func dibHeader(t *testing.T, data []byte) BitmapInfoHeaderV3 {
+	t.Helper()
+	header := BitmapInfoHeaderV3{}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	return header
+}
+
+func TestIconGroupWriteICO(t *testing.T) {
+	small, large := testImage(16, 16, false), testImage(32, 32, true)
+	group, err := NewIconGroup(ResourceName{ID: 1}, []IconSource{
+		{Image: small, Mask: alphaMask{small}, BPP: 32},
+		{Image: large, Mask: alphaMask{large}, BPP: 24},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.Buffer{}
+	group.WriteICO(&buf)
+	entries := parseICO(t, buf.Bytes(), 1)
+	if len(entries) != 2 {
+		t.Fatalf("%d images, want 2", len(entries))
+	}
+	for i, want := range []struct{ size, bpp int }{{16, 32}, {32, 24}} {
+		e := entries[i]
+		if int(e.Width) != want.size || int(e.Height) != want.size || int(e.BitCount) != want.bpp {
+			t.Errorf("image %d: %dx%d, %d bpp, want %dx%[4]d, %d bpp", i+1, e.Width, e.Height, e.BitCount, want.size, want.bpp)
+		}
+		header := dibHeader(t, e.data)
+		if int(header.Width) != want.size || int(header.Height) != 2*want.size || int(header.BPP) != want.bpp {
+			t.Errorf("image %d: DIB header %+v", i+1, header)
+		}
+		xor := bppstride(want.size, want.bpp) * want.size
+		and := bppstride(want.size, 1) * want.size
+		if len(e.data) != SizeOfBitmapInfoHeaderV3+xor+and {
+			t.Errorf("image %d: %d bytes, want %d", i+1, len(e.data), SizeOfBitmapInfoHeaderV3+xor+and)
+		}
+	}
+
+	// The transparent top-left quarter of the small image is set in the
+	// AND mask, whose rows are stored bottom-up.
+	mask := entries[0].data[len(entries[0].data)-16*4:]
+	if want := unhex(t, "ff 00 00 00"); !bytes.Equal(mask[len(mask)-4:], want) {
+		t.Errorf("top mask row % x, want % x", mask[len(mask)-4:], want)
+	}
+	if want := unhex(t, "00 00 00 00"); !bytes.Equal(mask[:4], want) {
+		t.Errorf("bottom mask row % x, want % x", mask[:4], want)
+	}
+}
+
+func TestNewIconGroupErrors(t *testing.T) {
+	if _, err := NewIconGroup(ResourceName{ID: 1}, nil); err == nil {
+		t.Error("NewIconGroup succeeded without images")
+	}
+	img := testImage(16, 16, true)
+	if _, err := NewIconGroup(ResourceName{ID: 1}, []IconSource{{Image: img, Mask: alphaMask{img}, BPP: 32}}); err == nil {
+		t.Error("NewIconGroup succeeded with the wrong bit depth")
+	}
+}
+
+func TestIconGroupPNG(t *testing.T) {
+	large, small := testImage(256, 256, false), testImage(16, 16, false)
+	data := EncodePNG(large)
+	group, err := NewIconGroup(ResourceName{ID: 1}, []IconSource{
+		{PNG: data},
+		{Image: small, Mask: alphaMask{small}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.Buffer{}
+	group.WriteICO(&buf)
+	entries := parseICO(t, buf.Bytes(), 1)
+	if len(entries) != 2 {
+		t.Fatalf("%d images, want 2", len(entries))
+	}
+	if e := entries[0]; e.Width != 0 || e.Height != 0 || e.BitCount != 32 || !bytes.Equal(e.data, data) {
+		t.Errorf("PNG image: %dx%d, %d bpp, %d bytes", e.Width, e.Height, e.BitCount, len(e.data))
+	}
+	if e := entries[1]; bytes.HasPrefix(e.data, PNGSignature[:]) {
+		t.Error("DIB image is stored as a PNG")
+	}
+
+	if _, err := NewPNGIconImage(data[1:], 0); err == nil {
+		t.Error("NewPNGIconImage succeeded without a PNG signature")
+	}
+}
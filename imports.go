@@ -0,0 +1,136 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Import is a DLL that a PE executable imports functions from.
+type Import struct {
+	DLL       string
+	Functions []ImportFunction
+}
+
+// ImportFunction is a function imported by name or, if Name is empty, by
+// ordinal.
+type ImportFunction struct {
+	Name string
+
+	// Hint is the index into the export name table of the DLL at which the
+	// loader looks for Name first.
+	Hint uint16
+
+	Ordinal uint16
+}
+
+// ParseImportFunction parses a function to import: a name, or an ordinal
+// prefixed with "#", such as "#23".
+func ParseImportFunction(s string) (ImportFunction, error) {
+	if ordinal, ok := strings.CutPrefix(s, "#"); ok {
+		n, err := strconv.ParseUint(ordinal, 0, 16)
+		if err != nil || n == 0 {
+			return ImportFunction{}, fmt.Errorf("invalid ordinal %q", s)
+		}
+		return ImportFunction{Ordinal: uint16(n)}, nil
+	}
+	if s == "" {
+		return ImportFunction{}, errors.New("empty function name")
+	}
+	return ImportFunction{Name: s}, nil
+}
+
+// checkImports verifies that every import names its DLL and each of its
+// functions.
+func checkImports(imports []Import) error {
+	for i, imp := range imports {
+		if imp.DLL == "" {
+			return fmt.Errorf("import %d: no DLL name", i+1)
+		}
+		if len(imp.Functions) == 0 {
+			return fmt.Errorf("import %s: no functions", imp.DLL)
+		}
+		for j, fn := range imp.Functions {
+			if fn.Name == "" && fn.Ordinal == 0 {
+				return fmt.Errorf("import %s: function %d has neither a name nor an ordinal", imp.DLL, j+1)
+			}
+		}
+	}
+	return nil
+}
+
+// encodeImports encodes the contents of an .idata section at rva: the import
+// address table, the import descriptors, the import lookup tables, and then
+// the hint/name entries and DLL names they point to. The lookup tables and
+// address tables are identical on disk; the loader overwrites the latter
+// with the addresses of the functions. Entries are 64-bit in PE32+
+// executables. iatSize is the size of the import address table; the
+// descriptors follow it.
+func encodeImports(imports []Import, exeFormat EXEFormat, rva uint32) (data []byte, iatSize int, err error) {
+	le := binary.LittleEndian
+	thunkSize := 4
+	if exeFormat == PE32Plus {
+		thunkSize = 8
+	}
+	for _, imp := range imports {
+		iatSize += (len(imp.Functions) + 1) * thunkSize
+	}
+	descriptorsSize := (len(imports) + 1) * SizeOfImageImportDescriptor
+	namesOffset := iatSize + descriptorsSize + iatSize
+
+	thunks := []byte{}
+	descriptors := bytes.Buffer{}
+	names := []byte{}
+	for _, imp := range imports {
+		descriptor := ImageImportDescriptor{
+			OriginalFirstThunk: rva + uint32(iatSize+descriptorsSize+len(thunks)),
+			FirstThunk:         rva + uint32(len(thunks)),
+		}
+		for _, fn := range imp.Functions {
+			var thunk uint64
+			if fn.Name == "" {
+				thunk = uint64(fn.Ordinal) | ImageOrdinalFlag32
+				if exeFormat == PE32Plus {
+					thunk = uint64(fn.Ordinal) | ImageOrdinalFlag64
+				}
+			} else {
+				thunk = uint64(rva) + uint64(namesOffset+len(names))
+				names = le.AppendUint16(names, fn.Hint)
+				if names, err = appendANSIZ(names, fn.Name, DefaultCodepage); err != nil {
+					return nil, 0, fmt.Errorf("import %s: %w", imp.DLL, err)
+				}
+				names = append(names, make([]byte, len(names)%2)...)
+			}
+			if exeFormat == PE32Plus {
+				thunks = le.AppendUint64(thunks, thunk)
+			} else {
+				thunks = le.AppendUint32(thunks, uint32(thunk))
+			}
+		}
+		thunks = append(thunks, make([]byte, thunkSize)...)
+		descriptor.Name = rva + uint32(namesOffset+len(names))
+		if names, err = appendANSIZ(names, imp.DLL, DefaultCodepage); err != nil {
+			return nil, 0, fmt.Errorf("import %s: %w", imp.DLL, err)
+		}
+		names = append(names, make([]byte, len(names)%2)...)
+		must(binary.Write(&descriptors, le, descriptor), "writing import descriptor")
+	}
+	descriptors.Write(make([]byte, SizeOfImageImportDescriptor))
+
+	data = append(data, thunks...)
+	data = append(data, descriptors.Bytes()...)
+	data = append(data, thunks...)
+	return append(data, names...), iatSize, nil
+}
@@ -0,0 +1,93 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// Enumeration of language IDs that have special meaning during resource
+// lookup.
+const (
+	LangNeutral       = 0x0000
+	LangUserDefault   = 0x0400
+	LangSystemDefault = 0x0800
+	LangEnglishUS     = 0x0409
+)
+
+// primaryLangCodepages maps primary language IDs to their ANSI codepage.
+// Languages that are not listed use codepage 1252.
+var primaryLangCodepages = map[uint16]uint32{
+	0x01: 1256, // Arabic
+	0x02: 1251, // Bulgarian
+	0x05: 1250, // Czech
+	0x08: 1253, // Greek
+	0x0d: 1255, // Hebrew
+	0x0e: 1250, // Hungarian
+	0x11: 932,  // Japanese
+	0x12: 949,  // Korean
+	0x15: 1250, // Polish
+	0x18: 1250, // Romanian
+	0x19: 1251, // Russian
+	0x1a: 1250, // Croatian
+	0x1b: 1250, // Slovak
+	0x1e: 874,  // Thai
+	0x1f: 1254, // Turkish
+	0x22: 1251, // Ukrainian
+	0x23: 1251, // Belarusian
+	0x24: 1250, // Slovenian
+	0x25: 1257, // Estonian
+	0x26: 1257, // Latvian
+	0x27: 1257, // Lithuanian
+	0x29: 1256, // Farsi
+	0x2a: 1258, // Vietnamese
+}
+
+// CodepageForLanguage returns the ANSI codepage conventionally used for
+// resources in the given language.
+func CodepageForLanguage(lang uint16) uint32 {
+	if lang&0x3ff == 0x04 {
+		// Chinese: Traditional for Taiwan, Hong Kong and Macau, otherwise
+		// Simplified.
+		switch lang {
+		case 0x0404, 0x0c04, 0x1404:
+			return 950
+		}
+		return 936
+	}
+	if codepage, ok := primaryLangCodepages[lang&0x3ff]; ok {
+		return codepage
+	}
+	return 1252
+}
+
+// codepageEncodings maps the ANSI codepages returned by CodepageForLanguage
+// to their encodings.
+var codepageEncodings = map[uint32]encoding.Encoding{
+	874:  charmap.Windows874,
+	932:  japanese.ShiftJIS,
+	936:  simplifiedchinese.GBK,
+	949:  korean.EUCKR,
+	950:  traditionalchinese.Big5,
+	1250: charmap.Windows1250,
+	1251: charmap.Windows1251,
+	1252: charmap.Windows1252,
+	1253: charmap.Windows1253,
+	1254: charmap.Windows1254,
+	1255: charmap.Windows1255,
+	1256: charmap.Windows1256,
+	1257: charmap.Windows1257,
+	1258: charmap.Windows1258,
+}
@@ -0,0 +1,92 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCodepageForLanguage(t *testing.T) {
+	for _, test := range []struct {
+		lang     uint16
+		codepage uint32
+	}{
+		{0x0409, 1252}, // en-US
+		{0x0407, 1252}, // de-DE
+		{0x0419, 1251}, // ru-RU
+		{0x0411, 932},  // ja-JP
+		{0x0804, 936},  // zh-CN
+		{0x0404, 950},  // zh-TW
+		{0x0000, 1252}, // LANG_NEUTRAL
+	} {
+		if got := CodepageForLanguage(test.lang); got != test.codepage {
+			t.Errorf("CodepageForLanguage(%#04x) = %d, want %d", test.lang, got, test.codepage)
+		}
+	}
+}
+
+func TestANSI(t *testing.T) {
+	for _, test := range []struct {
+		s        string
+		codepage uint32
+		want     string
+	}{
+		{"Grüße", 1252, "Gr\xfc\xdfe"},
+		{"Привет", 1251, "\xcf\xf0\xe8\xe2\xe5\xf2"},
+		{"日本", 932, "\x93\xfa\x96\x7b"},
+	} {
+		got, err := ansi(test.s, test.codepage)
+		if err != nil {
+			t.Errorf("ansi(%q, %d): %v", test.s, test.codepage, err)
+		} else if string(got) != test.want {
+			t.Errorf("ansi(%q, %d) = % x, want % x", test.s, test.codepage, got, test.want)
+		}
+	}
+	if _, err := ansi("日本", 1252); err == nil {
+		t.Error("ansi succeeded with characters outside of the codepage")
+	}
+	if _, err := ansi("A", 1200); err == nil {
+		t.Error("ansi succeeded with a non-ANSI codepage")
+	}
+}
+
+func TestResourceLanguages(t *testing.T) {
+	img := testImage(16, 16, false)
+	groups := []*IconGroup{}
+	for _, lang := range []uint16{0x0419, 0x0409} {
+		group, err := NewIconGroup(ResourceName{ID: 1}, []IconSource{{Image: img, Mask: alphaMask{img}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		group.Language = lang
+		groups = append(groups, group)
+	}
+	tree, err := (&Resources{Icons: groups}).Tree(PE32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.Buffer{}
+	tree.WritePE(&buf, 0x1000)
+	got := []peResource{}
+	for _, r := range readPEResources(t, buf.Bytes(), 0x1000) {
+		if r.typ == (ResourceName{ID: ResourceGroupIcon}) {
+			got = append(got, r)
+		}
+	}
+	if len(got) != 2 || got[0].lang != 0x0409 || got[0].codepage != 1252 || got[1].lang != 0x0419 || got[1].codepage != 1251 {
+		t.Errorf("group icon resources %+v", got)
+	}
+
+	groups[1].Language = groups[0].Language
+	if _, err := (&Resources{Icons: groups}).Tree(PE32); err == nil {
+		t.Error("duplicate icon groups were accepted")
+	}
+}
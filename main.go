@@ -10,8 +10,11 @@
 package main
 
 import (
+	"bytes"
 	"embed"
 	"encoding/binary"
+	"errors"
+	"flag"
 	"fmt"
 	"image"
 	"image/png"
@@ -19,6 +22,9 @@ import (
 	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 //go:embed asset/*
@@ -34,36 +40,6 @@ var (
 	imgMask  = loadpng("asset/mask.png")
 )
 
-// IconDirectoryEntry are entries of the GroupIconDirectory when stored on-disk.
-type IconDirectoryEntry struct {
-	Width       uint8
-	Height      uint8
-	ColorCount  uint8
-	Reserved    uint8
-	NumPlanes   uint16
-	BitCount    uint16
-	ImageSize   uint32
-	ImageOffset uint32
-}
-
-const SizeOfIconDirectoryEntry = 16
-
-const PEResourceDirOverhead = SizeOfResourceDirectoryTable +
-	SizeOfResourceDirectoryEntry*2 +
-	SizeOfResourceDirectoryTable +
-	SizeOfResourceDirectoryEntry +
-	SizeOfResourceDirectoryTable +
-	SizeOfResourceDirectoryEntry +
-	SizeOfResourceDataEntry +
-	SizeOfResourceDataEntry +
-	SizeOfResourceDirectoryTable +
-	SizeOfResourceDirectoryEntry +
-	SizeOfResourceDirectoryTable +
-	SizeOfResourceDirectoryEntry +
-	SizeOfResourceDataEntry +
-	SizeOfGroupIconDirectory +
-	SizeOfGroupIconDirectoryEntry
-
 type EXEFormat int
 
 const (
@@ -72,14 +48,686 @@ const (
 	PE32Plus
 )
 
+func (f EXEFormat) String() string {
+	switch f {
+	case NE16:
+		return "ne16"
+	case PE32:
+		return "pe32"
+	case PE32Plus:
+		return "pe32plus"
+	}
+	return fmt.Sprintf("EXEFormat(%d)", int(f))
+}
+
+func parseEXEFormat(name string) (EXEFormat, error) {
+	for _, f := range []EXEFormat{NE16, PE32, PE32Plus} {
+		if f.String() == name {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown format %q (want ne16, pe32 or pe32plus)", name)
+}
+
+func validBPP(nbit int) bool {
+	switch nbit {
+	case 0, 1, 4, 8, 16, 24, 32:
+		return true
+	}
+	return false
+}
+
+const usageText = `usage: make-mock-exe [flags] [image.png...]
+       make-mock-exe build manifest.json
+       make-mock-exe samples [-dir directory]
+
+Generates a mock executable with an icon, or a cursor, made up of the given
+images. The images may be left out when the executable has code, imports or
+exports. The build command generates every executable described by a JSON
+manifest. The samples command writes the built-in sample set into a
+directory (default "out").
+
+flags:
+`
+
 func main() {
-	png2exe(create("out/ne16-1bpp.exe"), create("out/1bpp.ico"), img1bpp, imgMask, NE16, 1)
-	png2exe(create("out/ne16-4bpp.exe"), create("out/4bpp.ico"), img4bpp, imgMask, NE16, 4)
-	png2exe(create("out/ne16-8bpp.exe"), create("out/8bpp.ico"), img8bpp, imgMask, NE16, 8)
-	png2exe(create("out/pe32-16bpp.exe"), create("out/16bpp.ico"), img16bpp, imgMask, PE32, 16)
-	png2exe(create("out/pe32-24bpp.exe"), create("out/24bpp.ico"), img24bpp, imgMask, PE32, 24)
-	png2exe(create("out/pe32-32bpp.exe"), create("out/32bpp.ico"), img32bpp, imgMask, PE32, 32)
-	png2exe(create("out/pe32plus-32bpp.exe"), io.Discard, img32bpp, imgMask, PE32Plus, 32)
+	log.SetFlags(0)
+	log.SetPrefix("make-mock-exe: ")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "build":
+			build(os.Args[2:])
+			return
+		case "samples":
+			samples(os.Args[2:])
+			return
+		}
+	}
+	convert(os.Args[1:])
+}
+
+func convert(args []string) {
+	flags := flag.NewFlagSet("make-mock-exe", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usageText)
+		flags.PrintDefaults()
+	}
+	maskPath := flags.String("mask", "", "mask `image`; white pixels are transparent (default: derived from alpha)")
+	formatName := flags.String("format", "pe32", "executable `format`: ne16, pe32 or pe32plus")
+	nbit := flags.Int("bpp", 0, "icon bit depth: 1, 4, 8, 16, 24 or 32 (default: depth of the image);\nindividual images may override it with a path:bpp suffix, and a\n:png suffix stores the image as a PNG stream instead of a DIB")
+	exePath := flags.String("o", "", "write the executable to `path` (required)")
+	icoPath := flags.String("ico", "", "also write the icon to an .ico file at `path`")
+	lang := flags.Uint("lang", DefaultLanguage, "language `ID` of the resources")
+	cursor := flags.Bool("cursor", false, "store the images as a cursor instead of an icon")
+	hotspotArg := flags.String("hotspot", "0,0", "hotspot of the cursor images as `x,y`")
+	curPath := flags.String("cur", "", "also write the cursor to a .cur file at `path` (requires -cursor)")
+	fileVersion := flags.String("file-version", "", "add version information with the given file and product `version`, such as 1.2.3.4")
+	library := flags.Bool("library", false, "mark the executable as a library module, like an .icl file or a DLL")
+	moduleName := flags.String("module-name", "", "module `name`; in PE executables, the DLL name of the exports (default: derived\nfrom the -o path)")
+	var program *Program
+	flags.Func("exit-code", "add an entry point that exits with the given `code`, making the executable\nrunnable (PE only)", func(arg string) error {
+		code, err := strconv.ParseUint(arg, 0, 32)
+		if err != nil {
+			return fmt.Errorf("invalid exit code %q", arg)
+		}
+		program = &Program{ExitCode: uint32(code)}
+		return nil
+	})
+	imports := []Import{}
+	flags.Func("import", "import functions from a DLL, given as `dll:functions`, where functions is a\ncomma-separated list of names and ordinals such as #23 (PE only; may be repeated)", func(arg string) error {
+		dll, functions, ok := strings.Cut(arg, ":")
+		if !ok || dll == "" {
+			return fmt.Errorf("expected dll:function,..., got %q", arg)
+		}
+		imp := Import{DLL: dll}
+		for _, name := range strings.Split(functions, ",") {
+			fn, err := ParseImportFunction(name)
+			if err != nil {
+				return err
+			}
+			imp.Functions = append(imp.Functions, fn)
+		}
+		imports = append(imports, imp)
+		return nil
+	})
+	exports := []Export{}
+	flags.Func("export", "export the function `name`, or an ordinal such as #7, optionally followed by\n@ordinal and =forwarder, such as Foo@3 or Bar=NTDLL.RtlBar (PE only; may be\nrepeated)", func(arg string) error {
+		export, err := ParseExport(arg)
+		if err != nil {
+			return err
+		}
+		exports = append(exports, export)
+		return nil
+	})
+	var tls *TLS
+	flags.Func("tls-callbacks", "add a TLS directory with the given `number` of TLS callbacks, which may be 0\n(PE only)", func(arg string) error {
+		n, err := strconv.ParseUint(arg, 0, 16)
+		if err != nil {
+			return fmt.Errorf("invalid number of TLS callbacks %q", arg)
+		}
+		tls = &TLS{Callbacks: int(n)}
+		return nil
+	})
+	dynamicBase := flags.Bool("dynamic-base", false, "mark the executable as compatible with ASLR, and make it relocatable (PE only)")
+	highEntropyVA := flags.Bool("high-entropy-va", false, "mark the executable as compatible with 64-bit ASLR (PE32+ only, requires\n-dynamic-base)")
+	nxCompat := flags.Bool("nx-compat", false, "mark the executable as compatible with DEP (PE only)")
+	appManifestPath := flags.String("app-manifest", "", "embed the application manifest at `path` (PE only)")
+	executionLevel := flags.String("execution-level", "", "embed a generated application manifest with the given requested execution\n`level`: asInvoker, highestAvailable or requireAdministrator (PE only)")
+	rawResources := []*RawResource{}
+	flags.Func("resource", "embed the file at `path` as-is, given as type:name:path, where type is a\nstandard type such as RCDATA or a custom type name (may be repeated)", func(arg string) error {
+		parts := strings.SplitN(arg, ":", 3)
+		if len(parts) != 3 {
+			return fmt.Errorf("expected type:name:path, got %q", arg)
+		}
+		typ, err := ParseResourceType(parts[0])
+		if err != nil {
+			return err
+		}
+		name, err := ParseResourceName(parts[1])
+		if err != nil {
+			return err
+		}
+		raw, err := LoadRawResource(typ, name, parts[2])
+		if err != nil {
+			return err
+		}
+		rawResources = append(rawResources, raw)
+		return nil
+	})
+	versionStrings := []VersionString{}
+	flags.Func("version-string", "add a `key=value` pair, such as CompanyName=Example, to the version information\n(may be repeated)", func(arg string) error {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("expected key=value, got %q", arg)
+		}
+		versionStrings = append(versionStrings, VersionString{Key: key, Value: value})
+		return nil
+	})
+	flags.Parse(args)
+
+	// Executables with code or exports are useful without an icon, so the
+	// images are only required when there are nothing but resources.
+	hasCode := program != nil || len(imports) != 0 || len(exports) != 0 || tls != nil
+	if flags.NArg() == 0 && !hasCode {
+		usageError(flags, "expected at least one input image")
+	}
+	if *exePath == "" {
+		usageError(flags, "missing -o")
+	}
+	if *maskPath != "" && flags.NArg() > 1 {
+		usageError(flags, "-mask can only be used with a single image")
+	}
+	exeFormat, err := parseEXEFormat(*formatName)
+	if err != nil {
+		usageError(flags, "%v", err)
+	}
+	if !validBPP(*nbit) {
+		usageError(flags, "unsupported bit depth %d", *nbit)
+	}
+	if *lang > 0xffff {
+		usageError(flags, "invalid language ID %#x", *lang)
+	}
+	if *cursor && *icoPath != "" {
+		usageError(flags, "-ico cannot be used with -cursor")
+	}
+	if !*cursor && *curPath != "" {
+		usageError(flags, "-cur requires -cursor")
+	}
+	if flags.NArg() == 0 && (*icoPath != "" || *curPath != "") {
+		usageError(flags, "-ico and -cur require at least one input image")
+	}
+	var hotspot image.Point
+	if _, err := fmt.Sscanf(*hotspotArg, "%d,%d", &hotspot.X, &hotspot.Y); err != nil {
+		usageError(flags, "invalid hotspot %q", *hotspotArg)
+	}
+
+	sources := []IconSource{}
+	for _, arg := range flags.Args() {
+		name, bpp, asPNG, err := parseImageArg(arg, *nbit)
+		if err != nil {
+			usageError(flags, "%v", err)
+		}
+		if asPNG {
+			if *maskPath != "" {
+				usageError(flags, "-mask cannot be used with :png images")
+			}
+			data, err := os.ReadFile(name)
+			must(err, "loading image")
+			sources = append(sources, IconSource{PNG: data, BPP: bpp, Hotspot: hotspot})
+			continue
+		}
+		img, err := loadPNGFile(name)
+		must(err, "loading image")
+		var mask image.Image = alphaMask{img}
+		if *maskPath != "" {
+			mask, err = loadPNGFile(*maskPath)
+			must(err, "loading mask")
+		}
+		sources = append(sources, IconSource{Image: img, Mask: mask, BPP: bpp, Hotspot: hotspot})
+	}
+
+	res := &Resources{
+		ModuleName:    *moduleName,
+		Library:       *library,
+		Program:       program,
+		Imports:       imports,
+		Exports:       exports,
+		TLS:           tls,
+		DynamicBase:   *dynamicBase,
+		HighEntropyVA: *highEntropyVA,
+		NXCompat:      *nxCompat,
+	}
+	if res.ModuleName == "" {
+		res.ModuleName = defaultModuleName(*exePath, exeFormat)
+	}
+	var group *IconGroup
+	var cursorGroup *CursorGroup
+	switch {
+	case len(sources) == 0:
+		// An executable without an icon or a cursor.
+	case *cursor:
+		if cursorGroup, err = NewCursorGroup(ResourceName{ID: 1}, sources); err != nil {
+			usageError(flags, "%v", err)
+		}
+		cursorGroup.Language = uint16(*lang)
+		res.Cursors = []*CursorGroup{cursorGroup}
+	default:
+		if group, err = NewIconGroup(ResourceName{ID: 1}, sources); err != nil {
+			usageError(flags, "%v", err)
+		}
+		group.Language = uint16(*lang)
+		res.Icons = []*IconGroup{group}
+	}
+	if *fileVersion != "" || len(versionStrings) != 0 {
+		res.Version = &VersionInfo{ResourceLanguage: ResourceLanguage{Language: uint16(*lang)}}
+		if *fileVersion != "" {
+			if res.Version.FileVersion, err = ParseVersion(*fileVersion); err != nil {
+				usageError(flags, "%v", err)
+			}
+			res.Version.ProductVersion = res.Version.FileVersion
+		}
+		if len(versionStrings) != 0 {
+			res.Version.StringTables = []VersionStringTable{{
+				VersionTranslation: VersionTranslation{Language: uint16(*lang)},
+				Strings:            versionStrings,
+			}}
+		}
+	}
+	for _, raw := range rawResources {
+		raw.Language = uint16(*lang)
+	}
+	res.Raw = rawResources
+	if *appManifestPath != "" && *executionLevel != "" {
+		usageError(flags, "-app-manifest and -execution-level are mutually exclusive")
+	}
+	if *appManifestPath != "" {
+		data, err := os.ReadFile(*appManifestPath)
+		must(err, "loading application manifest")
+		res.Manifest = &AppManifest{ResourceLanguage: ResourceLanguage{Language: uint16(*lang)}, XML: data}
+	}
+	if *executionLevel != "" {
+		res.Manifest = &AppManifest{ResourceLanguage: ResourceLanguage{Language: uint16(*lang)}, ExecutionLevel: *executionLevel}
+	}
+	var exeBuf bytes.Buffer
+	if err := png2exe(&exeBuf, res, exeFormat); err != nil {
+		usageError(flags, "%v", err)
+	}
+	must(writeOutput(*exePath, exeBuf.Bytes()), "writing %q", *exePath)
+	if *icoPath != "" {
+		var icoBuf bytes.Buffer
+		group.WriteICO(&icoBuf)
+		must(writeOutput(*icoPath, icoBuf.Bytes()), "writing %q", *icoPath)
+	}
+	if *curPath != "" {
+		var curBuf bytes.Buffer
+		cursorGroup.WriteCUR(&curBuf)
+		must(writeOutput(*curPath, curBuf.Bytes()), "writing %q", *curPath)
+	}
+}
+
+// defaultModuleName derives a module name from the path of an executable:
+// its base name, which for NE executables is without the extension and in
+// upper case.
+func defaultModuleName(path string, exeFormat EXEFormat) string {
+	base := filepath.Base(path)
+	if exeFormat != NE16 {
+		return base
+	}
+	return strings.ToUpper(strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
+// parseImageArg parses an image argument of the form path[:bpp][:png].
+func parseImageArg(arg string, defaultBPP int) (name string, nbit int, asPNG bool, err error) {
+	name, nbit = arg, defaultBPP
+	if strings.HasSuffix(name, ":png") {
+		name, asPNG = strings.TrimSuffix(name, ":png"), true
+	}
+	i := strings.LastIndexByte(name, ':')
+	if i < 0 {
+		return name, nbit, asPNG, nil
+	}
+	n, err := strconv.Atoi(name[i+1:])
+	if err != nil {
+		// Not a bit depth; treat the colon as part of the path.
+		return name, nbit, asPNG, nil
+	}
+	if !validBPP(n) {
+		return "", 0, false, fmt.Errorf("%s: unsupported bit depth %d", arg, n)
+	}
+	return name[:i], n, asPNG, nil
+}
+
+func samples(args []string) {
+	flags := flag.NewFlagSet("make-mock-exe samples", flag.ExitOnError)
+	dir := flags.String("dir", "out", "output `directory`")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		usageError(flags, "unexpected arguments")
+	}
+
+	group := func(name ResourceName, sources ...IconSource) *IconGroup {
+		g, err := NewIconGroup(name, sources)
+		must(err, "processing icon group %s", name)
+		return g
+	}
+	write := func(exeName string, exeFormat EXEFormat, res *Resources) {
+		must(png2exe(create(filepath.Join(*dir, exeName)), res, exeFormat), "generating %q", exeName)
+	}
+	sample := func(exeName, icoName string, exeFormat EXEFormat, groups ...*IconGroup) {
+		if icoName != "" {
+			groups[0].WriteICO(create(filepath.Join(*dir, icoName)))
+		}
+		write(exeName, exeFormat, &Resources{Icons: groups})
+	}
+	src1bpp := IconSource{Image: img1bpp, Mask: imgMask, BPP: 1}
+	src4bpp := IconSource{Image: img4bpp, Mask: imgMask, BPP: 4}
+	src8bpp := IconSource{Image: img8bpp, Mask: imgMask, BPP: 8}
+	src16bpp := IconSource{Image: img16bpp, Mask: imgMask, BPP: 16}
+	src24bpp := IconSource{Image: img24bpp, Mask: imgMask, BPP: 24}
+	src32bpp := IconSource{Image: img32bpp, Mask: imgMask, BPP: 32}
+	id1 := ResourceName{ID: 1}
+	sample("ne16-1bpp.exe", "1bpp.ico", NE16, group(id1, src1bpp))
+	sample("ne16-4bpp.exe", "4bpp.ico", NE16, group(id1, src4bpp))
+	sample("ne16-8bpp.exe", "8bpp.ico", NE16, group(id1, src8bpp))
+	sample("pe32-16bpp.exe", "16bpp.ico", PE32, group(id1, src16bpp))
+	sample("pe32-24bpp.exe", "24bpp.ico", PE32, group(id1, src24bpp))
+	sample("pe32-32bpp.exe", "32bpp.ico", PE32, group(id1, src32bpp))
+	sample("pe32plus-32bpp.exe", "", PE32Plus, group(id1, src32bpp))
+	sample("ne16-multi.exe", "", NE16, group(id1, src1bpp, src4bpp, src8bpp))
+	sample("pe32-multi.exe", "multi.ico", PE32, group(id1, src1bpp, src4bpp, src8bpp, src24bpp, src32bpp))
+
+	groups := []*IconGroup{
+		group(ResourceName{ID: 5}, src8bpp),
+		group(ResourceName{Name: "MainIcon"}, src4bpp),
+		group(ResourceName{ID: 2}, src1bpp),
+		group(ResourceName{Name: "App"}, src8bpp),
+	}
+	sample("ne16-groups.exe", "", NE16, groups...)
+	sample("pe32-groups.exe", "", PE32, groups...)
+
+	srcPNG := IconSource{PNG: EncodePNG(upscale(img32bpp, 4))}
+	sample("pe32-png.exe", "png.ico", PE32, group(id1, src8bpp, src32bpp, srcPNG))
+
+	localized := func(lang uint16, sources ...IconSource) *IconGroup {
+		g := group(id1, sources...)
+		g.Language = lang
+		return g
+	}
+	groups = []*IconGroup{
+		localized(LangEnglishUS, src32bpp),
+		localized(LangNeutral, src8bpp),
+		localized(0x0411, src4bpp), // ja-JP, codepage 932
+		localized(0x0419, src1bpp), // ru-RU, codepage 1251
+	}
+	sample("ne16-lang.exe", "", NE16, groups[1])
+	sample("pe32-lang.exe", "", PE32, groups...)
+
+	cursor := func(hotspot image.Point, sources ...IconSource) *CursorGroup {
+		for i := range sources {
+			sources[i].Hotspot = hotspot
+		}
+		g, err := NewCursorGroup(id1, sources)
+		must(err, "processing cursor group")
+		return g
+	}
+	cursor32 := cursor(image.Pt(8, 4), src1bpp, src4bpp, src32bpp)
+	cursor32.WriteCUR(create(filepath.Join(*dir, "cursor.cur")))
+	write("ne16-cursor.exe", NE16, &Resources{Cursors: []*CursorGroup{cursor(image.Pt(8, 4), src1bpp, src4bpp)}})
+	write("pe32-cursor.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Cursors: []*CursorGroup{cursor32}})
+
+	animated := &AnimatedCursor{
+		Name:             id1,
+		ResourceLanguage: ResourceLanguage{Language: LangEnglishUS},
+		Frames: []*CursorGroup{
+			cursor(image.Pt(8, 4), src1bpp),
+			cursor(image.Pt(8, 4), src4bpp),
+			cursor(image.Pt(8, 4), src8bpp),
+		},
+		Rates:    []uint32{30, 10, 10, 10},
+		Sequence: []uint32{0, 1, 2, 1},
+		Title:    "Mock cursor",
+		Artist:   "make-mock-exe",
+	}
+	must(animated.WriteANI(create(filepath.Join(*dir, "animated.ani"))), "writing animated.ani")
+	animatedIcon := *animated
+	animatedIcon.Icon = true
+	write("pe32-ani.exe", PE32, &Resources{
+		Icons:           []*IconGroup{group(id1, src32bpp)},
+		AnimatedCursors: []*AnimatedCursor{animated, &animatedIcon},
+	})
+
+	bitmap := func(name ResourceName, img image.Image, nbit int) *Bitmap {
+		b, err := NewBitmap(name, img, nbit)
+		must(err, "processing bitmap %s", name)
+		return b
+	}
+	bitmaps := []*Bitmap{
+		bitmap(ResourceName{ID: 1}, img1bpp, 1),
+		bitmap(ResourceName{ID: 4}, img4bpp, 4),
+		bitmap(ResourceName{ID: 8}, img8bpp, 8),
+		bitmap(ResourceName{Name: "Logo"}, img24bpp, 24),
+	}
+	must(bitmaps[2].DIB.WriteBMP(create(filepath.Join(*dir, "8bpp.bmp"))), "writing 8bpp.bmp")
+	must(bitmaps[3].DIB.WriteBMP(create(filepath.Join(*dir, "24bpp.bmp"))), "writing 24bpp.bmp")
+	write("ne16-bitmap.exe", NE16, &Resources{Icons: []*IconGroup{group(id1, src4bpp)}, Bitmaps: bitmaps})
+	raw := []*RawResource{
+		NewRawResource(ResourceName{ID: ResourceRCData}, ResourceName{ID: 1}, []byte("key=value\r\n")),
+		NewRawResource(ResourceName{ID: ResourceRCData}, ResourceName{Name: "License"}, []byte("CC0 1.0 Universal\r\n")),
+		NewRawResource(ResourceName{Name: "PNG"}, ResourceName{ID: 1}, EncodePNG(img8bpp)),
+	}
+	write("ne16-raw.exe", NE16, &Resources{Icons: []*IconGroup{group(id1, src4bpp)}, Raw: raw})
+	write("pe32-raw.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Raw: raw})
+	about := &Dialog{
+		Name:             ResourceName{Name: "About"},
+		ResourceLanguage: ResourceLanguage{Language: LangEnglishUS},
+		Style:            WindowStylePopup | WindowStyleCaption | WindowStyleSysMenu | DialogStyleModalFrame,
+		Width:            180,
+		Height:           70,
+		Title:            "About",
+		Font:             &DialogFont{PointSize: 8, Name: "MS Sans Serif"},
+		Controls: []DialogControl{
+			{ID: 0xffff, Class: ResourceName{ID: DialogClassStatic}, Text: ResourceName{ID: 1}, Style: WindowStyleChild | WindowStyleVisible | StaticStyleIcon, X: 8, Y: 8, Width: 20, Height: 20},
+			{ID: 0xffff, Class: ResourceName{ID: DialogClassStatic}, Text: ResourceName{Name: "Mock executable"}, Style: WindowStyleChild | WindowStyleVisible, X: 40, Y: 10, Width: 130, Height: 8},
+			{ID: 100, Class: ResourceName{ID: DialogClassEdit}, Style: WindowStyleChild | WindowStyleVisible | WindowStyleBorder | WindowStyleTabStop | EditStyleAutoHScroll, X: 40, Y: 24, Width: 130, Height: 12},
+			{ID: 1, Class: ResourceName{ID: DialogClassButton}, Text: ResourceName{Name: "OK"}, Style: WindowStyleChild | WindowStyleVisible | WindowStyleTabStop | ButtonStyleDefPushButton, X: 120, Y: 48, Width: 50, Height: 14},
+		},
+	}
+	aboutEx := *about
+	aboutEx.Name = ResourceName{Name: "AboutEx"}
+	aboutEx.Extended = true
+	aboutEx.Style |= DialogStyleShellFont
+	aboutEx.Font = &DialogFont{PointSize: 9, Name: "Segoe UI", Weight: 400, Charset: 1}
+	write("ne16-dialog.exe", NE16, &Resources{Icons: []*IconGroup{group(id1, src4bpp)}, Dialogs: []*Dialog{about}})
+	write("pe32-dialog.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Dialogs: []*Dialog{about, &aboutEx}})
+	write("pe32-bitmap.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Bitmaps: append(bitmaps, bitmap(ResourceName{ID: 32}, img32bpp, 32))})
+	mainMenu := &Menu{
+		Name:             ResourceName{ID: 1},
+		ResourceLanguage: ResourceLanguage{Language: LangEnglishUS},
+		Items: []MenuItem{
+			{Text: "&File", Items: []MenuItem{
+				{Text: "&Open...\tCtrl+O", ID: 100},
+				{Text: "&Save\tCtrl+S", ID: 101, Flags: MenuFlagGrayed, State: MenuStateGrayed},
+				{Separator: true},
+				{Text: "Recent &Files", Items: []MenuItem{
+					{Text: "mock.txt", ID: 110, Flags: MenuFlagChecked, State: MenuStateChecked},
+				}},
+				{Text: "E&xit", ID: 102},
+			}},
+			{Text: "&Help", Items: []MenuItem{
+				{Text: "&About...\tF1", ID: 200},
+			}},
+		},
+	}
+	mainMenuEx := *mainMenu
+	mainMenuEx.Name = ResourceName{ID: 2}
+	mainMenuEx.Extended = true
+	mainMenuEx.HelpID = 1000
+	accelerators := &AcceleratorTable{
+		Name:             ResourceName{ID: 1},
+		ResourceLanguage: ResourceLanguage{Language: LangEnglishUS},
+		Entries: []Accelerator{
+			{Key: 'O', Flags: AcceleratorVirtKey | AcceleratorControl, ID: 100},
+			{Key: 'S', Flags: AcceleratorVirtKey | AcceleratorControl, ID: 101},
+			{Key: 0x70, Flags: AcceleratorVirtKey, ID: 200},
+			{Key: 'q', ID: 102},
+		},
+	}
+	write("ne16-menu.exe", NE16, &Resources{Icons: []*IconGroup{group(id1, src4bpp)}, Menus: []*Menu{mainMenu}, Accelerators: []*AcceleratorTable{accelerators}})
+	write("pe32-menu.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Menus: []*Menu{mainMenu, &mainMenuEx}, Accelerators: []*AcceleratorTable{accelerators}})
+
+	version := &VersionInfo{
+		ResourceLanguage: ResourceLanguage{Language: LangEnglishUS},
+		FileVersion:      [4]uint16{1, 2, 3, 4},
+		ProductVersion:   [4]uint16{1, 2, 0, 0},
+		StringTables: []VersionStringTable{
+			{VersionTranslation{Language: LangEnglishUS}, []VersionString{
+				{"CompanyName", "Mock Software"},
+				{"FileDescription", "Mock executable"},
+				{"FileVersion", "1.2.3.4"},
+				{"ProductName", "make-mock-exe"},
+				{"ProductVersion", "1.2"},
+			}},
+			{VersionTranslation{Language: 0x0407}, []VersionString{ // de-DE
+				{"CompanyName", "Mock Software"},
+				{"FileDescription", "Beispielprogramm"},
+				{"FileVersion", "1.2.3.4"},
+				{"ProductName", "make-mock-exe"},
+				{"ProductVersion", "1.2"},
+			}},
+		},
+	}
+	write("ne16-version.exe", NE16, &Resources{Icons: []*IconGroup{group(id1, src4bpp)}, Version: version})
+	write("pe32-version.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Version: version})
+
+	manifest := &AppManifest{
+		ResourceLanguage: ResourceLanguage{Language: LangEnglishUS},
+		ExecutionLevel:   "asInvoker",
+		DPIAware:         "true/pm",
+		DPIAwareness:     "PerMonitorV2, PerMonitor",
+		SupportedOS:      []string{"vista", "win7", "win8", "win81", "win10"},
+	}
+	write("pe32-manifest.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Version: version, Manifest: manifest})
+	write("pe32plus-manifest.exe", PE32Plus, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Version: version, Manifest: manifest})
+
+	stringTables := []*StringTable{
+		{ResourceLanguage: ResourceLanguage{Language: LangEnglishUS}, Strings: map[uint16]string{
+			1:   "Mock executable",
+			2:   "Hello, world!",
+			15:  "Last string of block 1",
+			16:  "First string of block 2",
+			100: "Caf\u00e9",
+		}},
+		{ResourceLanguage: ResourceLanguage{Language: 0x0407}, Strings: map[uint16]string{ // de-DE
+			1: "Beispielprogramm",
+			2: "Hallo, Welt!",
+		}},
+	}
+	write("ne16-strings.exe", NE16, &Resources{Icons: []*IconGroup{group(id1, src4bpp)}, Strings: stringTables[:1]})
+	write("pe32-strings.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Strings: stringTables})
+
+	messageTables := []*MessageTable{
+		{ResourceLanguage: ResourceLanguage{Language: LangEnglishUS}, Messages: map[uint32]string{
+			1:          "The operation completed successfully.\r\n",
+			2:          "The mock service started.\r\n",
+			3:          "The mock service stopped with status %1.\r\n",
+			0x40000100: "Informational message.\r\n",
+			0xC0000100: "Error message: %1\r\n",
+		}},
+		{ResourceLanguage: ResourceLanguage{Language: 0x0407}, ANSI: true, Messages: map[uint32]string{ // de-DE
+			1: "Der Vorgang wurde erfolgreich beendet.\r\n",
+			2: "Der Beispieldienst wurde gestartet.\r\n",
+		}},
+	}
+	write("pe32-messages.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, MessageTables: messageTables})
+
+	font := func(id uint16, img image.Image, cellWidth int, ascent uint16) *Font {
+		f, err := NewFontFromStrip(ResourceName{ID: id}, "Mock Sans", img, ' ', cellWidth)
+		must(err, "processing font %d", id)
+		f.Copyright = "CC0 1.0 Universal"
+		f.Ascent = ascent
+		f.Family = FontFamilySwiss
+		f.DefaultChar = '.'
+		return f
+	}
+	glyphs := mockGlyphStrip()
+	fonts := []*Font{
+		font(1, glyphs, 6, 7),
+		font(2, upscale(glyphs, 2), 12, 14),
+	}
+	write("mock.fon", NE16, &Resources{Fonts: fonts, ModuleName: "MOCK", Library: true})
+
+	libraryIcons := []*IconGroup{}
+	for i, sources := 0, [][]IconSource{{src1bpp}, {src4bpp}, {src8bpp}, {src1bpp, src4bpp}, {src4bpp, src8bpp}}; i < 40; i++ {
+		libraryIcons = append(libraryIcons, group(ResourceName{ID: uint16(i + 1)}, sources[i%len(sources)]...))
+	}
+	write("icons.icl", NE16, &Resources{Icons: libraryIcons, ModuleName: "ICONS", Library: true})
+	srcLarge := IconSource{Image: upscale(img24bpp, 4), Mask: upscale(imgMask, 4), BPP: 24}
+	sample("ne16-large.exe", "", NE16, group(id1, srcLarge))
+
+	write("pe32-runnable.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Program: &Program{}})
+	write("pe32plus-runnable.exe", PE32Plus, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Program: &Program{ExitCode: 42}})
+
+	imports := []Import{
+		{DLL: "KERNEL32.dll", Functions: []ImportFunction{{Name: "ExitProcess", Hint: 0x167}, {Name: "GetModuleHandleW"}}},
+		{DLL: "USER32.dll", Functions: []ImportFunction{{Name: "MessageBoxW"}}},
+		{DLL: "WS2_32.dll", Functions: []ImportFunction{{Ordinal: 23}, {Ordinal: 115}}},
+	}
+	write("pe32-imports.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Program: &Program{}, Imports: imports})
+	write("pe32plus-imports.exe", PE32Plus, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Program: &Program{}, Imports: imports})
+
+	exports := []Export{
+		{Name: "MockFunction"},
+		{Name: "AnotherMockFunction"},
+		{Name: "MockOrdinal", Ordinal: 10},
+		{Ordinal: 12},
+		{Name: "HeapAlloc", Forwarder: "NTDLL.RtlAllocateHeap"},
+	}
+	write("pe32-mock.dll", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Program: &Program{}, Exports: exports, ModuleName: "pe32-mock.dll", Library: true})
+	write("pe32plus-mock.dll", PE32Plus, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Program: &Program{}, Exports: exports, ModuleName: "pe32plus-mock.dll", Library: true})
+
+	write("pe32-aslr.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Program: &Program{}, DynamicBase: true, NXCompat: true})
+	write("pe32plus-aslr.exe", PE32Plus, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Program: &Program{}, DynamicBase: true, HighEntropyVA: true, NXCompat: true})
+
+	write("pe32-tls.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Program: &Program{}, TLS: &TLS{Callbacks: 1}})
+	write("pe32-tls-empty.exe", PE32, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Program: &Program{}, TLS: &TLS{}})
+	write("pe32plus-tls.exe", PE32Plus, &Resources{Icons: []*IconGroup{group(id1, src32bpp)}, Program: &Program{}, TLS: &TLS{Data: []byte("mock"), ZeroFill: 12, Callbacks: 3}, DynamicBase: true})
+}
+
+// mockGlyphs is a 5x7 glyph set covering the characters from ' ' to '9'.
+// Characters without glyphs are blank.
+var mockGlyphs = map[byte][7]string{
+	'!': {"..#..", "..#..", "..#..", "..#..", "..#..", ".....", "..#.."},
+	'+': {".....", "..#..", "..#..", "#####", "..#..", "..#..", "....."},
+	',': {".....", ".....", ".....", ".....", ".##..", "..#..", ".#..."},
+	'-': {".....", ".....", ".....", "#####", ".....", ".....", "....."},
+	'.': {".....", ".....", ".....", ".....", ".....", ".##..", ".##.."},
+	'/': {"....#", "...#.", "...#.", "..#..", ".#...", ".#...", "#...."},
+	'0': {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1': {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+}
+
+// mockGlyphStrip draws mockGlyphs as a strip of 6x8 cells, black on white.
+func mockGlyphStrip() image.Image {
+	img := image.NewGray(image.Rect(0, 0, 6*('9'-' '+1), 8))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for c, rows := range mockGlyphs {
+		for y, row := range rows {
+			for x, pixel := range row {
+				if pixel == '#' {
+					img.Pix[y*img.Stride+6*int(c-' ')+x] = 0
+				}
+			}
+		}
+	}
+	return img
+}
+
+// upscale enlarges img by an integer factor using nearest-neighbor sampling.
+func upscale(img image.Image, factor int) image.Image {
+	b := img.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, b.Dx()*factor, b.Dy()*factor))
+	for y := 0; y < out.Rect.Dy(); y++ {
+		for x := 0; x < out.Rect.Dx(); x++ {
+			out.Set(x, y, img.At(b.Min.X+x/factor, b.Min.Y+y/factor))
+		}
+	}
+	return out
+}
+
+func usageError(flags *flag.FlagSet, format string, args ...any) {
+	fmt.Fprintf(flags.Output(), "%s: %s\n", flags.Name(), fmt.Sprintf(format, args...))
+	flags.Usage()
+	os.Exit(2)
 }
 
 func loadpng(name string) image.Image {
@@ -100,297 +748,307 @@ func create(name string) *os.File {
 	return f
 }
 
-func png2exe(exeWriter io.Writer, icoWriter io.Writer, img image.Image, mask image.Image, exeFormat EXEFormat, nbit int) {
+func loadPNGFile(name string) (image.Image, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %q: %w", name, err)
+	}
+	return img, nil
+}
+
+func png2exe(w io.Writer, res *Resources, exeFormat EXEFormat) error {
 	dosHeader := ImageDOSHeader{
 		Signature:     MZSignature,
 		NewHeaderAddr: uint32(SizeOfImageDOSHeader),
 	}
-	dib, err := NewDIB(img, mask, nbit)
-	must(err, "processing image")
-	must(binary.Write(exeWriter, binary.LittleEndian, dosHeader), "writing DOS header")
+	tree, err := res.Tree(exeFormat)
+	if err != nil {
+		return err
+	}
 	switch exeFormat {
 	case NE16:
-		ne16(exeWriter, icoWriter, dib)
-	case PE32:
-		pe32(exeWriter, dib)
-		peresource(exeWriter, icoWriter, dib)
-	case PE32Plus:
-		pe32plus(exeWriter, dib)
-		peresource(exeWriter, icoWriter, dib)
+		if res.Program != nil {
+			return errors.New("programs are not supported in NE executables")
+		}
+		if len(res.Imports) != 0 {
+			return errors.New("imports are not supported in NE executables")
+		}
+		if len(res.Exports) != 0 {
+			return errors.New("exports are not supported in NE executables")
+		}
+		if res.TLS != nil {
+			return errors.New("TLS is not supported in NE executables")
+		}
+		if res.DynamicBase || res.HighEntropyVA || res.NXCompat {
+			return errors.New("DLL characteristics are not supported in NE executables")
+		}
+		if err := checkNEResources(tree); err != nil {
+			return err
+		}
+		description, err := ansi(res.neDescription(), DefaultCodepage)
+		if err != nil {
+			return fmt.Errorf("module description: %w", err)
+		}
+		if len(description) > 0xff {
+			return fmt.Errorf("module description is too long (%d bytes)", len(description))
+		}
+		moduleName, err := ansi(res.ModuleName, DefaultCodepage)
+		if err != nil {
+			return fmt.Errorf("module name: %w", err)
+		}
+		if len(moduleName) > 0xff {
+			return fmt.Errorf("module name is too long (%d bytes)", len(moduleName))
+		}
+		must(binary.Write(w, binary.LittleEndian, dosHeader), "writing DOS header")
+		if err := ne16(w, tree, res); err != nil {
+			return err
+		}
+	case PE32, PE32Plus:
+		img, err := newPEImage(exeFormat, res, tree)
+		if err != nil {
+			return err
+		}
+		must(binary.Write(w, binary.LittleEndian, dosHeader), "writing DOS header")
+		img.write(w)
 	}
+	return nil
 }
 
-func ne16(exeWriter io.Writer, icoWriter io.Writer, dib *DIB) {
-	resourceTableSize := SizeOfNEResourceTableHeader + 3*SizeOfNEResourceTableEntry + 2*SizeOfNEResource
-	residentNameTableSize := 4
-	headerSize := SizeOfNEFileHeader + resourceTableSize + residentNameTableSize
-	groupIconSize := SizeOfGroupIconDirectory + SizeOfGroupIconDirectoryEntry
+// checkNEResources verifies that the resources fit in an NE resource table,
+// where integer IDs are limited to 15 bits, names to 255 bytes, and each
+// resource to a single language.
+func checkNEResources(tree *ResourceTree) error {
+	checkName := func(name ResourceName) error {
+		b, err := ansi(name.Name, DefaultCodepage)
+		if err != nil {
+			return fmt.Errorf("resource name %s: %w", name, err)
+		}
+		if len(b) > 0xff {
+			return fmt.Errorf("resource name %s is too long", name)
+		}
+		if !name.IsName() && name.ID >= 0x8000 {
+			return fmt.Errorf("resource ID %d is too large", name.ID)
+		}
+		return nil
+	}
+	for _, typeNode := range tree.types {
+		if err := checkName(typeNode.typ); err != nil {
+			return err
+		}
+		if len(typeNode.names) > 0xffff {
+			return fmt.Errorf("too many resources of type %s", typeNode.typ)
+		}
+		for _, nameNode := range typeNode.names {
+			if err := checkName(nameNode.name); err != nil {
+				return err
+			}
+			if len(nameNode.langs) > 1 {
+				return fmt.Errorf("resource %s of type %s has %d languages, but NE executables can only hold one", nameNode.name, typeNode.typ, len(nameNode.langs))
+			}
+		}
+	}
+	return nil
+}
 
-	// These offsets are relative to the NE header
-	resourceTableOffset := SizeOfNEFileHeader
-	residentNameTableOffset := SizeOfNEFileHeader + resourceTableSize
+// neResourceData returns the data stored for a resource in NE executables.
+// The NE format has no concept of resource languages, so checkNEResources
+// only lets resources have a single language.
+func neResourceData(nameNode *resourceNameNode) []byte {
+	return nameNode.langs[0].data
+}
 
-	// These two offsets are relative to the beginning of the file
-	groupIconOffset := SizeOfImageDOSHeader + headerSize
-	iconOffset := SizeOfImageDOSHeader + headerSize + groupIconSize
+// neMaxAlignmentShift is the largest alignment shift count ne16 uses, which
+// allows for files of up to 2 GiB.
+const neMaxAlignmentShift = 15
 
-	must(binary.Write(exeWriter, binary.LittleEndian, NEFileHeader{
-		Signature:                 NESignature,
-		OffsetOfResourceTable:     uint16(resourceTableOffset),
-		OffsetOfResidentNameTable: uint16(residentNameTableOffset),
-		ExecutableType:            2,
-	}), "writing NE16 header")
-	shift := 1
-	must(binary.Write(exeWriter, binary.LittleEndian, NEResourceTableHeader{
-		AlignmentShiftCount: uint16(shift),
-	}), "writing NE16 resource table header")
-	must(binary.Write(exeWriter, binary.LittleEndian, NEResourceTableEntry{
-		TypeID:       ResourceIcon ^ 0x8000,
-		NumResources: 1,
-	}), "writing NE16 resource table icon entry")
-	must(binary.Write(exeWriter, binary.LittleEndian, NEResource{
-		DataOffsetShifted: uint16(iconOffset) >> shift,
-		DataLength:        uint16(dib.size),
-		Flags:             0x1c10,
-		ResourceID:        0x8001,
-	}), "writing NE16 resource icon resource")
-	must(binary.Write(exeWriter, binary.LittleEndian, NEResourceTableEntry{
-		TypeID:       ResourceGroupIcon ^ 0x8000,
-		NumResources: 1,
-	}), "writing NE16 resource group icon entry")
-	must(binary.Write(exeWriter, binary.LittleEndian, NEResource{
-		DataOffsetShifted: uint16(groupIconOffset) >> shift,
-		DataLength:        uint16(groupIconSize),
-		Flags:             0x1c10,
-		ResourceID:        0x8001,
-	}), "writing NE16 resource group icon resource")
-	must(binary.Write(exeWriter, binary.LittleEndian, NEResourceTableEntry{}), "writing NE16 terminal resource entry")
-
-	must(binary.Write(exeWriter, binary.LittleEndian, make([]byte, residentNameTableSize)), "writing blank resident name table")
-
-	w := io.MultiWriter(exeWriter, icoWriter)
-
-	// Group icon directory
-	must(binary.Write(w, binary.LittleEndian, GroupIconDirectory{
-		Type:  1,
-		Count: 1,
-	}), "writing group icon directory")
-
-	// Group icon directory entry
-	must(binary.Write(exeWriter, binary.LittleEndian, GroupIconDirectoryEntry{
-		Width:      uint8(dib.IconGroupWidth()),
-		Height:     uint8(dib.IconGroupHeight()),
-		ColorCount: uint8(dib.numColors),
-		NumPlanes:  1,
-		BPP:        uint16(dib.bpp),
-		ImageSize:  uint32(dib.size),
-		ResourceID: 1,
-	}), "writing group icon directory entry (exe)")
-
-	must(binary.Write(icoWriter, binary.LittleEndian, IconDirectoryEntry{
-		Width:       uint8(dib.IconGroupWidth()),
-		Height:      uint8(dib.IconGroupHeight()),
-		ColorCount:  uint8(dib.numColors),
-		NumPlanes:   1,
-		ImageSize:   uint32(dib.size),
-		ImageOffset: uint32(SizeOfGroupIconDirectory + SizeOfIconDirectoryEntry),
-	}), "writing icon directory entry (ico)")
-
-	dib.Write(w)
+// neAlignmentShift returns the smallest alignment shift count with which
+// resources of the given sizes, stored from dataOffset onwards, can be
+// addressed. The offsets and lengths of resources are 16-bit values in units
+// of the alignment, so larger files need larger alignments.
+func neAlignmentShift(dataOffset int, sizes []int) (int, error) {
+	for shift := 1; shift <= neMaxAlignmentShift; shift++ {
+		alignment := 1 << shift
+		offset := align(dataOffset, alignment)
+		fits := true
+		for _, size := range sizes {
+			if offset>>shift > 0xffff || align(size, alignment)>>shift > 0xffff {
+				fits = false
+				break
+			}
+			offset = align(offset+size, alignment)
+		}
+		if fits {
+			return shift, nil
+		}
+	}
+	return 0, errors.New("resources are too large for an NE executable")
 }
 
-func pe32(w io.Writer, dib *DIB) {
-	resDirSize := PEResourceDirOverhead + dib.size
-	optHeader := ImageOptionalHeaderPE32{
-		Magic:               ImageNTOptionalHeaderPE32Magic,
-		ImageBase:           0x400000,
-		SectionAlignment:    0x1000,
-		FileAlignment:       0x200,
-		SizeOfImage:         uint32(0x200 + resDirSize),
-		SizeOfHeaders:       0x200,
-		Subsystem:           2,
-		NumberOfRvaAndSizes: 15,
-	}
-	optHeader.DataDirectory[ImageDirectoryEntryResource] = ImageDataDirectory{
-		VirtualAddress: 0x1000,
-		Size:           uint32(resDirSize),
-	}
-	newHeader := ImageNTHeadersPE32{
-		Signature: PESignature,
-		FileHeader: ImageFileHeader{
-			Machine:              ImageFileMachinei386,
-			NumberOfSections:     1,
-			SizeOfOptionalHeader: SizeOfImageOptionalHeaderPE32,
-		},
-		OptionalHeader: optHeader,
+func ne16(exeWriter io.Writer, tree *ResourceTree, res *Resources) error {
+	// Type and resource names are stored after the type table, and are
+	// referred to by their offset from the start of the resource table.
+	numResources := 0
+	names := []string{}
+	nameOffsets := map[string]int{}
+	addName := func(name ResourceName) {
+		if _, ok := nameOffsets[name.Name]; name.IsName() && !ok {
+			names = append(names, name.Name)
+			nameOffsets[name.Name] = 0
+		}
+	}
+	for _, typeNode := range tree.types {
+		addName(typeNode.typ)
+		for _, nameNode := range typeNode.names {
+			addName(nameNode.name)
+			numResources++
+		}
+	}
+	resourceNamesOffset := SizeOfNEResourceTableHeader +
+		len(tree.types)*SizeOfNEResourceTableEntry +
+		numResources*SizeOfNEResource +
+		2
+	resourceNames := []byte{}
+	for _, name := range names {
+		b, err := ansi(name, DefaultCodepage)
+		if err != nil {
+			return err
+		}
+		nameOffsets[name] = resourceNamesOffset + len(resourceNames)
+		resourceNames = append(resourceNames, byte(len(b)))
+		resourceNames = append(resourceNames, b...)
+	}
+	resourceNames = append(resourceNames, 0)
+	neName := func(name ResourceName) uint16 {
+		if name.IsName() {
+			return uint16(nameOffsets[name.Name])
+		}
+		return 0x8000 | name.ID
 	}
-	must(binary.Write(w, binary.LittleEndian, newHeader), "writing PE32 header")
 
-	section := ImageSectionHeader{
-		PhysicalAddressOrVirtualSize: 0x8000,
-		VirtualAddress:               0x1000,
-		SizeOfRawData:                uint32(resDirSize),
-		PointerToRawData:             0x200,
-		Characteristics:              ImageSectionCharacteristicsMemoryRead | ImageSectionCharacteristicsMemoryWrite | ImageSectionCharacteristicsContainsInitializedData,
+	// The module name is the first entry of the resident name table, and
+	// the description the first entry of the non-resident name table. Both
+	// have the ordinal 0. Without a module name, the resident name table is
+	// left blank; without a description, there is no non-resident name
+	// table at all.
+	nameTableEntry := func(name string) ([]byte, error) {
+		b, err := ansi(name, DefaultCodepage)
+		if err != nil {
+			return nil, err
+		}
+		entry := append([]byte{byte(len(b))}, b...)
+		return append(entry, 0, 0, 0), nil
+	}
+	residentNameTable := make([]byte, 4)
+	if res.ModuleName != "" {
+		entry, err := nameTableEntry(res.ModuleName)
+		if err != nil {
+			return err
+		}
+		residentNameTable = entry
+	}
+	nonResidentNameTable := []byte{}
+	if description := res.neDescription(); description != "" {
+		entry, err := nameTableEntry(description)
+		if err != nil {
+			return err
+		}
+		nonResidentNameTable = entry
+	}
+	// Libraries share one data segment between every task that uses them,
+	// as .fon and .icl files declare.
+	flag := uint16(0)
+	if res.Library {
+		flag |= NEFlagLibrary | NEFlagSingleData
 	}
-	copy(section.Name[:], ".rsrc")
-	must(binary.Write(w, binary.LittleEndian, section), "writing section")
 
-	currentOffset := SizeOfImageDOSHeader + SizeOfImageNTHeadersPE32 + SizeOfImageSectionHeader
-	_, err := w.Write(make([]byte, 0x200-currentOffset))
-	must(err, "writing padding to first section")
-}
+	resourceTableSize := resourceNamesOffset + len(resourceNames)
+	residentNameTableSize := len(residentNameTable)
+	headerSize := SizeOfNEFileHeader + resourceTableSize + residentNameTableSize + len(nonResidentNameTable)
 
-func pe32plus(w io.Writer, dib *DIB) {
-	resDirSize := PEResourceDirOverhead + dib.size
-	optHeader := ImageOptionalHeaderPE32Plus{
-		Magic:               ImageNTOptionalHeaderPE32PlusMagic,
-		ImageBase:           0x400000,
-		SectionAlignment:    0x1000,
-		FileAlignment:       0x200,
-		SizeOfImage:         uint32(0x200 + resDirSize),
-		SizeOfHeaders:       0x200,
-		Subsystem:           2,
-		NumberOfRvaAndSizes: 15,
-	}
-	optHeader.DataDirectory[ImageDirectoryEntryResource] = ImageDataDirectory{
-		VirtualAddress: 0x1000,
-		Size:           uint32(resDirSize),
-	}
-	newHeader := ImageNTHeadersPE32Plus{
-		Signature: PESignature,
-		FileHeader: ImageFileHeader{
-			Machine:              ImageFileMachineAMD64,
-			NumberOfSections:     1,
-			SizeOfOptionalHeader: SizeOfImageOptionalHeaderPE32Plus,
-		},
-		OptionalHeader: optHeader,
+	// These offsets are relative to the NE header
+	resourceTableOffset := SizeOfNEFileHeader
+	residentNameTableOffset := SizeOfNEFileHeader + resourceTableSize
+	if residentNameTableOffset+residentNameTableSize > 0xffff {
+		return errors.New("NE resource and resident name tables are too large")
+	}
+
+	// These offsets are relative to the beginning of the file
+	nonResidentNameTableOffset := 0
+	if len(nonResidentNameTable) != 0 {
+		nonResidentNameTableOffset = SizeOfImageDOSHeader + residentNameTableOffset + residentNameTableSize
 	}
-	must(binary.Write(w, binary.LittleEndian, newHeader), "writing PE32+ header")
+	sizes := []int{}
+	for _, typeNode := range tree.types {
+		for _, nameNode := range typeNode.names {
+			sizes = append(sizes, len(neResourceData(nameNode)))
+		}
+	}
+	shift, err := neAlignmentShift(SizeOfImageDOSHeader+headerSize, sizes)
+	if err != nil {
+		return err
+	}
+	alignment := 1 << shift
+	dataOffset := align(SizeOfImageDOSHeader+headerSize, alignment)
 
-	section := ImageSectionHeader{
-		PhysicalAddressOrVirtualSize: 0x8000,
-		VirtualAddress:               0x1000,
-		SizeOfRawData:                uint32(resDirSize),
-		PointerToRawData:             0x200,
-		Characteristics:              ImageSectionCharacteristicsMemoryRead | ImageSectionCharacteristicsMemoryWrite | ImageSectionCharacteristicsContainsInitializedData,
+	must(binary.Write(exeWriter, binary.LittleEndian, NEFileHeader{
+		Signature:                    NESignature,
+		Flag:                         flag,
+		OffsetOfResourceTable:        uint16(resourceTableOffset),
+		OffsetOfResidentNameTable:    uint16(residentNameTableOffset),
+		NonResidentNameTableSize:     uint16(len(nonResidentNameTable)),
+		OffsetOfNonResidentNameTable: uint32(nonResidentNameTableOffset),
+		ExecutableType:               2,
+	}), "writing NE16 header")
+	must(binary.Write(exeWriter, binary.LittleEndian, NEResourceTableHeader{
+		AlignmentShiftCount: uint16(shift),
+	}), "writing NE16 resource table header")
+	offset := dataOffset
+	for _, typeNode := range tree.types {
+		must(binary.Write(exeWriter, binary.LittleEndian, NEResourceTableEntry{
+			TypeID:       neName(typeNode.typ),
+			NumResources: uint16(len(typeNode.names)),
+		}), "writing NE16 resource table entry")
+		for _, nameNode := range typeNode.names {
+			data := neResourceData(nameNode)
+			must(binary.Write(exeWriter, binary.LittleEndian, NEResource{
+				DataOffsetShifted: uint16(offset >> shift),
+				DataLength:        uint16(align(len(data), alignment) >> shift),
+				Flags:             0x1c10,
+				ResourceID:        neName(nameNode.name),
+			}), "writing NE16 resource")
+			offset = align(offset+len(data), alignment)
+		}
 	}
-	copy(section.Name[:], ".rsrc")
-	must(binary.Write(w, binary.LittleEndian, section), "writing section")
+	must(binary.Write(exeWriter, binary.LittleEndian, uint16(0)), "writing NE16 terminal resource entry")
+	_, err = exeWriter.Write(resourceNames)
+	must(err, "writing NE16 resource names")
 
-	currentOffset := SizeOfImageDOSHeader + SizeOfImageNTHeadersPE32Plus + SizeOfImageSectionHeader
-	_, err := w.Write(make([]byte, 0x200-currentOffset))
-	must(err, "writing padding to header")
-}
+	_, err = exeWriter.Write(residentNameTable)
+	must(err, "writing NE16 resident name table")
+	_, err = exeWriter.Write(nonResidentNameTable)
+	must(err, "writing NE16 non-resident name table")
 
-func peresource(exeWriter io.Writer, icoWriter io.Writer, dib *DIB) {
-	iconResDirOffset := SizeOfResourceDirectoryTable + SizeOfResourceDirectoryEntry*2
-	iconResDir2Offset := iconResDirOffset + SizeOfResourceDirectoryTable + SizeOfResourceDirectoryEntry
-	iconResDataEntryOffset := iconResDir2Offset + SizeOfResourceDirectoryTable + SizeOfResourceDirectoryEntry
-	groupIconResDirOffset := iconResDataEntryOffset + SizeOfResourceDataEntry
-	groupIconResDir2Offset := groupIconResDirOffset + SizeOfResourceDirectoryTable + SizeOfResourceDirectoryEntry
-	groupIconDataEntryOffset := groupIconResDir2Offset + SizeOfResourceDirectoryTable + SizeOfResourceDirectoryEntry
-	groupIconOffset := groupIconDataEntryOffset + SizeOfResourceDataEntry
-	groupIconSize := SizeOfGroupIconDirectory + SizeOfGroupIconDirectoryEntry
-	iconOffset := groupIconOffset + groupIconSize
-
-	// Root directory
-	must(binary.Write(exeWriter, binary.LittleEndian, ResourceDirectoryTable{
-		NumIDEntries: 2,
-		MajorVersion: 4,
-	}), "writing root resource dir")
-	must(binary.Write(exeWriter, binary.LittleEndian, ResourceDirectoryEntry{
-		ID:     ResourceIcon,
-		Offset: 0x80000000 | uint32(iconResDirOffset),
-	}), "writing resource icon dir root entry")
-	must(binary.Write(exeWriter, binary.LittleEndian, ResourceDirectoryEntry{
-		ID:     ResourceGroupIcon,
-		Offset: 0x80000000 | uint32(groupIconResDirOffset),
-	}), "writing resource icon group dir root entry")
-
-	// Icon resource directory
-	must(binary.Write(exeWriter, binary.LittleEndian, ResourceDirectoryTable{
-		NumIDEntries: 1,
-		MajorVersion: 4,
-	}), "writing icon resource dir")
-	must(binary.Write(exeWriter, binary.LittleEndian, ResourceDirectoryEntry{
-		ID:     1,
-		Offset: 0x80000000 | uint32(iconResDir2Offset),
-	}), "writing icon resource dir entry")
-
-	// Icon resource directory 2
-	must(binary.Write(exeWriter, binary.LittleEndian, ResourceDirectoryTable{
-		NumIDEntries: 1,
-		MajorVersion: 4,
-	}), "writing icon resource dir 2")
-	must(binary.Write(exeWriter, binary.LittleEndian, ResourceDirectoryEntry{
-		ID:     1033,
-		Offset: uint32(iconResDataEntryOffset),
-	}), "writing icon resource dir entry 2")
-
-	// Icon data entry
-	must(binary.Write(exeWriter, binary.LittleEndian, ResourceDataEntry{
-		DataRVA:  0x1000 + uint32(iconOffset),
-		Size:     uint32(dib.size),
-		Codepage: 1252,
-	}), "writing icon data entry")
-
-	// Group icon resources directory
-	must(binary.Write(exeWriter, binary.LittleEndian, ResourceDirectoryTable{
-		NumIDEntries: 1,
-		MajorVersion: 4,
-	}), "writing icon group resource dir")
-	must(binary.Write(exeWriter, binary.LittleEndian, ResourceDirectoryEntry{
-		ID:     1,
-		Offset: 0x80000000 | uint32(groupIconResDir2Offset),
-	}), "writing icon group resource dir entry")
-
-	// Group icon resources directory 2
-	must(binary.Write(exeWriter, binary.LittleEndian, ResourceDirectoryTable{
-		NumIDEntries: 1,
-		MajorVersion: 4,
-	}), "writing group icon resource dir 2")
-	must(binary.Write(exeWriter, binary.LittleEndian, ResourceDirectoryEntry{
-		ID:     1033,
-		Offset: uint32(groupIconDataEntryOffset),
-	}), "writing group icon resource dir 2 entry")
-
-	// Group icon data entry
-	must(binary.Write(exeWriter, binary.LittleEndian, ResourceDataEntry{
-		DataRVA:  0x1000 + uint32(groupIconOffset),
-		Size:     uint32(groupIconSize),
-		Codepage: 1252,
-	}), "writing group icon data entry")
-
-	w := io.MultiWriter(exeWriter, icoWriter)
-
-	// Group icon directory
-	must(binary.Write(w, binary.LittleEndian, GroupIconDirectory{
-		Type:  1,
-		Count: 1,
-	}), "writing group icon directory")
-
-	// Group icon directory entry
-	must(binary.Write(exeWriter, binary.LittleEndian, GroupIconDirectoryEntry{
-		Width:      uint8(dib.IconGroupWidth()),
-		Height:     uint8(dib.IconGroupHeight()),
-		ColorCount: uint8(dib.numColors),
-		NumPlanes:  1,
-		BPP:        uint16(dib.bpp),
-		ImageSize:  uint32(dib.size),
-		ResourceID: 1,
-	}), "writing group icon directory entry (exe)")
-
-	must(binary.Write(icoWriter, binary.LittleEndian, IconDirectoryEntry{
-		Width:       uint8(dib.IconGroupWidth()),
-		Height:      uint8(dib.IconGroupHeight()),
-		ColorCount:  uint8(dib.numColors),
-		NumPlanes:   1,
-		ImageSize:   uint32(dib.size),
-		ImageOffset: uint32(SizeOfGroupIconDirectory + SizeOfIconDirectoryEntry),
-	}), "writing icon directory entry (ico)")
-
-	dib.Write(w)
+	// Each resource is padded to the alignment, so that the whole of the
+	// length recorded for it is present in the file.
+	offset = SizeOfImageDOSHeader + headerSize
+	for _, typeNode := range tree.types {
+		for _, nameNode := range typeNode.names {
+			data := neResourceData(nameNode)
+			_, err := exeWriter.Write(make([]byte, align(offset, alignment)-offset))
+			must(err, "writing NE16 resource padding")
+			_, err = exeWriter.Write(data)
+			must(err, "writing NE16 resource data")
+			offset = align(offset, alignment) + len(data)
+		}
+	}
+	_, err = exeWriter.Write(make([]byte, align(offset, alignment)-offset))
+	must(err, "writing NE16 resource padding")
+	return nil
 }
 
 func must(err error, format string, args ...any) {
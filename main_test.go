@@ -0,0 +1,118 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// parseNE generates the NE executable for res, returning it and its NE
+// header. Offsets in the header are relative to the NE header, at
+// SizeOfImageDOSHeader, except for OffsetOfNonResidentNameTable.
+func parseNE(t *testing.T, res *Resources) ([]byte, NEFileHeader) {
+	t.Helper()
+	buf := bytes.Buffer{}
+	if err := png2exe(&buf, res, NE16); err != nil {
+		t.Fatal(err)
+	}
+	header := NEFileHeader{}
+	if err := binary.Read(bytes.NewReader(buf.Bytes()[SizeOfImageDOSHeader:]), binary.LittleEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Signature != NESignature {
+		t.Fatalf("NE signature %q", header.Signature[:])
+	}
+	return buf.Bytes(), header
+}
+
+func testNEResources() *Resources {
+	return &Resources{Raw: []*RawResource{NewRawResource(ResourceName{ID: ResourceRCData}, ResourceName{ID: 1}, []byte("mock"))}}
+}
+
+func TestNENames(t *testing.T) {
+	res := testNEResources()
+	res.Library = true
+	res.ModuleName = "MOCK"
+	res.Description = "Mock library"
+	data, header := parseNE(t, res)
+	if header.Flag != NEFlagLibrary|NEFlagSingleData {
+		t.Errorf("flags %#04x", header.Flag)
+	}
+	resident := data[SizeOfImageDOSHeader+int(header.OffsetOfResidentNameTable):]
+	if want := "\x04MOCK\x00\x00\x00"; !strings.HasPrefix(string(resident), want) {
+		t.Errorf("resident name table %q, want %q", resident[:len(want)], want)
+	}
+	nonResident := data[header.OffsetOfNonResidentNameTable:][:header.NonResidentNameTableSize]
+	if want := "\x0cMock library\x00\x00\x00"; string(nonResident) != want {
+		t.Errorf("non-resident name table %q, want %q", nonResident, want)
+	}
+
+	data, header = parseNE(t, testNEResources())
+	if header.Flag != 0 || header.NonResidentNameTableSize != 0 || header.OffsetOfNonResidentNameTable != 0 {
+		t.Errorf("executable without names: header %+v", header)
+	}
+	resident = data[SizeOfImageDOSHeader+int(header.OffsetOfResidentNameTable):]
+	if !bytes.HasPrefix(resident, make([]byte, 4)) {
+		t.Errorf("blank resident name table % x", resident[:4])
+	}
+
+	res.ModuleName = strings.Repeat("X", 0x100)
+	if err := png2exe(&bytes.Buffer{}, res, NE16); err == nil {
+		t.Error("png2exe succeeded with a 256-byte module name")
+	}
+}
+
+func TestNEAlignmentShift(t *testing.T) {
+	for _, test := range []struct {
+		dataOffset int
+		sizes      []int
+		want       int
+	}{
+		{0x100, []int{10}, 1},
+		{0x100, []int{0x20000}, 2},
+		{0x100, []int{0x1fffe, 0x1fffe}, 2}, // the second offset needs 17 bits
+		{0x1ffff, []int{1}, 2},
+	} {
+		got, err := neAlignmentShift(test.dataOffset, test.sizes)
+		if err != nil || got != test.want {
+			t.Errorf("neAlignmentShift(%#x, %#x) = %d, %v, want %d", test.dataOffset, test.sizes, got, err, test.want)
+		}
+	}
+	if _, err := neAlignmentShift(0x100, []int{1 << 31}); err == nil {
+		t.Error("neAlignmentShift succeeded with a 2 GiB resource")
+	}
+}
+
+func TestNELargeResource(t *testing.T) {
+	large := bytes.Repeat([]byte("mock"), 0x8000)
+	res := testNEResources()
+	res.Raw = append(res.Raw, NewRawResource(ResourceName{ID: ResourceRCData}, ResourceName{ID: 2}, large))
+	data, header := parseNE(t, res)
+	table := bytes.NewReader(data[SizeOfImageDOSHeader+int(header.OffsetOfResourceTable):])
+	shift := uint16(0)
+	entry := NEResourceTableEntry{}
+	resources := make([]NEResource, 2)
+	for _, v := range []any{&shift, &entry, resources} {
+		if err := binary.Read(table, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if shift != 2 {
+		t.Errorf("alignment shift %d, want 2", shift)
+	}
+	r := resources[1]
+	offset, length := int(r.DataOffsetShifted)<<shift, int(r.DataLength)<<shift
+	if length < len(large) || offset+len(large) > len(data) || !bytes.Equal(data[offset:offset+len(large)], large) {
+		t.Errorf("resource at %#x (%#x bytes) does not hold the data", offset, length)
+	}
+}
@@ -0,0 +1,1240 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Manifest describes a batch of mock executables to generate. It is stored
+// as JSON; relative paths inside of it are resolved against the directory
+// containing the manifest file.
+type Manifest struct {
+	Outputs []ManifestOutput `json:"outputs"`
+}
+
+// ManifestOutput describes a single executable in a Manifest.
+type ManifestOutput struct {
+	// Output is the path of the executable to write.
+	Output string `json:"output"`
+
+	// Format is the executable format: ne16, pe32 or pe32plus.
+	Format string `json:"format"`
+
+	// A single icon group may be given inline; otherwise, Icons lists each
+	// of the icon groups in the executable.
+	ManifestIcon
+	Icons []ManifestIcon `json:"icons,omitempty"`
+
+	// Cursors lists the cursor groups in the executable. Optional.
+	Cursors []ManifestCursor `json:"cursors,omitempty"`
+
+	// AnimatedCursors lists the animated cursors and icons in the
+	// executable. Optional; PE only.
+	AnimatedCursors []ManifestAnimatedCursor `json:"animated_cursors,omitempty"`
+
+	// Bitmaps lists the bitmaps in the executable. Optional.
+	Bitmaps []ManifestBitmap `json:"bitmaps,omitempty"`
+
+	// Dialogs lists the dialog templates in the executable. Optional.
+	Dialogs []ManifestDialog `json:"dialogs,omitempty"`
+
+	// Menus lists the menus in the executable. Optional.
+	Menus []ManifestMenu `json:"menus,omitempty"`
+
+	// Accelerators lists the accelerator tables in the executable.
+	// Optional.
+	Accelerators []ManifestAccelerators `json:"accelerators,omitempty"`
+
+	// Fonts lists the raster fonts in the executable. An ne16 output with
+	// only fonts is a .fon file. Optional.
+	Fonts []ManifestFont `json:"fonts,omitempty"`
+
+	// Description is the module description of ne16 outputs. Defaults to a
+	// FONTRES description if there are fonts. Optional.
+	Description string `json:"description,omitempty"`
+
+	// ModuleName is the module name of ne16 outputs, and the DLL name of
+	// the exports of pe32 and pe32plus outputs. Defaults to the base name of
+	// Output, without the extension and in upper case for ne16 outputs.
+	ModuleName string `json:"module_name,omitempty"`
+
+	// Library marks outputs as library modules, like .icl and .fon files or
+	// DLLs.
+	Library bool `json:"library,omitempty"`
+
+	// Program adds an entry point to pe32 and pe32plus outputs, so that they
+	// can be run. Optional.
+	Program *ManifestProgram `json:"program,omitempty"`
+
+	// Imports lists the DLLs that the executable imports functions from.
+	// Optional; PE only.
+	Imports []ManifestImport `json:"imports,omitempty"`
+
+	// Exports lists the functions that the executable exports. Optional; PE
+	// only.
+	Exports []ManifestExport `json:"exports,omitempty"`
+
+	// TLS adds a TLS directory to pe32 and pe32plus outputs. Optional.
+	TLS *ManifestTLS `json:"tls,omitempty"`
+
+	// DynamicBase, HighEntropyVA and NXCompat mark pe32 and pe32plus
+	// outputs as compatible with ASLR, 64-bit ASLR and DEP. DynamicBase
+	// also makes them relocatable. HighEntropyVA is pe32plus only, and
+	// requires DynamicBase.
+	DynamicBase   bool `json:"dynamic_base,omitempty"`
+	HighEntropyVA bool `json:"high_entropy_va,omitempty"`
+	NXCompat      bool `json:"nx_compat,omitempty"`
+
+	// Resources lists resources of any type that are stored as-is.
+	// Optional.
+	Resources []ManifestResource `json:"resources,omitempty"`
+
+	// Version is the version information of the executable. Optional.
+	Version *ManifestVersion `json:"version,omitempty"`
+
+	// AppManifest is the application manifest of the executable. Optional;
+	// PE only.
+	AppManifest *ManifestAppManifest `json:"app_manifest,omitempty"`
+
+	// Strings lists a string table for each language. Optional.
+	Strings []ManifestStringTable `json:"strings,omitempty"`
+
+	// MessageTables lists a message table for each language. Optional; PE
+	// only.
+	MessageTables []ManifestMessageTable `json:"message_tables,omitempty"`
+}
+
+// ManifestLanguage describes the language of a resource in a Manifest.
+type ManifestLanguage struct {
+	// Language is the language ID of the resource. Defaults to 1033
+	// (en-US); 0 is LANG_NEUTRAL.
+	Language *uint16 `json:"language,omitempty"`
+
+	// Codepage is the codepage recorded for the resource. If zero, the
+	// conventional ANSI codepage of the language is used.
+	Codepage uint32 `json:"codepage,omitempty"`
+}
+
+func (m ManifestLanguage) resourceLanguage() ResourceLanguage {
+	lang := ResourceLanguage{Language: DefaultLanguage, Codepage: m.Codepage}
+	if m.Language != nil {
+		lang.Language = *m.Language
+	}
+	return lang
+}
+
+// ManifestGroup describes the properties shared by icon and cursor groups
+// in a Manifest.
+type ManifestGroup struct {
+	// ID is the integer ID of the group. If neither ID nor Name are given,
+	// the groups are numbered sequentially starting from 1.
+	ID uint16 `json:"id,omitempty"`
+
+	// Name is the string name of the group. Takes precedence over ID.
+	Name string `json:"name,omitempty"`
+
+	ManifestLanguage
+
+	// The group may be given either as a single image inline, or as a list
+	// of images that make up one multi-resolution icon or cursor.
+	ManifestImage
+	Images []ManifestImage `json:"images,omitempty"`
+}
+
+// ManifestIcon describes an icon group in a Manifest.
+type ManifestIcon struct {
+	ManifestGroup
+
+	// ICO is the path of a companion .ico file to write. Optional.
+	ICO string `json:"ico,omitempty"`
+}
+
+// ManifestCursor describes a cursor group in a Manifest.
+type ManifestCursor struct {
+	ManifestGroup
+
+	// CUR is the path of a companion .cur file to write. Optional.
+	CUR string `json:"cur,omitempty"`
+}
+
+// ManifestAnimatedCursor describes an animated cursor or icon in a
+// Manifest.
+type ManifestAnimatedCursor struct {
+	// ID is the integer ID of the resource. If neither ID nor Name are given,
+	// the animations are numbered sequentially starting from 1.
+	ID uint16 `json:"id,omitempty"`
+
+	// Name is the string name of the resource. Takes precedence over ID.
+	Name string `json:"name,omitempty"`
+
+	ManifestLanguage
+
+	// Icon stores an animated icon rather than an animated cursor.
+	Icon bool `json:"icon,omitempty"`
+
+	// ANI is the path of a companion .ani file to write. Optional.
+	ANI string `json:"ani,omitempty"`
+
+	// Frames lists one image per frame.
+	Frames []ManifestImage `json:"frames"`
+
+	// Rate is the default time each step is shown, in jiffies (1/60 s).
+	// Defaults to 10.
+	Rate uint32 `json:"rate,omitempty"`
+
+	// Rates optionally lists the time each step is shown, in jiffies.
+	Rates []uint32 `json:"rates,omitempty"`
+
+	// Sequence optionally lists the frame shown at each step, counting from
+	// 0.
+	Sequence []uint32 `json:"sequence,omitempty"`
+
+	Title  string `json:"title,omitempty"`
+	Artist string `json:"artist,omitempty"`
+}
+
+// ManifestBitmap describes a bitmap in a Manifest.
+type ManifestBitmap struct {
+	// ID is the integer ID of the bitmap. If neither ID nor Name are given,
+	// the bitmaps are numbered sequentially starting from 1.
+	ID uint16 `json:"id,omitempty"`
+
+	// Name is the string name of the bitmap. Takes precedence over ID.
+	Name string `json:"name,omitempty"`
+
+	ManifestLanguage
+
+	// Image is the path of the PNG image.
+	Image string `json:"image"`
+
+	// BPP is the bit depth of the bitmap. If zero, the depth of the image is
+	// used.
+	BPP int `json:"bpp,omitempty"`
+
+	// BMP is the path of a companion .bmp file to write. Optional.
+	BMP string `json:"bmp,omitempty"`
+}
+
+// ManifestDialog describes a dialog template in a Manifest.
+type ManifestDialog struct {
+	// ID is the integer ID of the dialog. If neither ID nor Name are given,
+	// the dialogs are numbered sequentially starting from 1.
+	ID uint16 `json:"id,omitempty"`
+
+	// Name is the string name of the dialog. Takes precedence over ID.
+	Name string `json:"name,omitempty"`
+
+	ManifestLanguage
+
+	// Extended selects the DLGTEMPLATEEX layout. PE only.
+	Extended bool `json:"extended,omitempty"`
+
+	// Style defaults to WS_POPUP | WS_BORDER | WS_SYSMENU, like rc.
+	Style   uint32 `json:"style,omitempty"`
+	ExStyle uint32 `json:"ex_style,omitempty"`
+	HelpID  uint32 `json:"help_id,omitempty"`
+
+	X      int16 `json:"x"`
+	Y      int16 `json:"y"`
+	Width  int16 `json:"width"`
+	Height int16 `json:"height"`
+
+	// Menu and Class are optional. "#n" or a number is an integer ID.
+	Menu  string `json:"menu,omitempty"`
+	Class string `json:"class,omitempty"`
+	Title string `json:"title,omitempty"`
+
+	Font *ManifestDialogFont `json:"font,omitempty"`
+
+	Controls []ManifestDialogControl `json:"controls,omitempty"`
+}
+
+// ManifestDialogFont describes the font of a dialog in a Manifest.
+type ManifestDialogFont struct {
+	Size    uint16 `json:"size"`
+	Name    string `json:"name"`
+	Weight  uint16 `json:"weight,omitempty"`
+	Italic  bool   `json:"italic,omitempty"`
+	Charset uint8  `json:"charset,omitempty"`
+}
+
+// ManifestDialogControl describes a dialog control in a Manifest.
+type ManifestDialogControl struct {
+	ID uint32 `json:"id"`
+
+	// Class is a predefined class, such as BUTTON or EDIT, or the name of a
+	// window class.
+	Class string `json:"class"`
+
+	// Text is the window text. TextID, if non-zero, refers to a resource
+	// such as an icon instead.
+	Text   string `json:"text,omitempty"`
+	TextID uint16 `json:"text_id,omitempty"`
+
+	// Style is combined with WS_CHILD | WS_VISIBLE.
+	Style   uint32 `json:"style,omitempty"`
+	ExStyle uint32 `json:"ex_style,omitempty"`
+	HelpID  uint32 `json:"help_id,omitempty"`
+
+	X      int16 `json:"x"`
+	Y      int16 `json:"y"`
+	Width  int16 `json:"width"`
+	Height int16 `json:"height"`
+}
+
+// ManifestMenu describes a menu in a Manifest.
+type ManifestMenu struct {
+	// ID is the integer ID of the menu. If neither ID nor Name are given,
+	// the menus are numbered sequentially starting from 1.
+	ID uint16 `json:"id,omitempty"`
+
+	// Name is the string name of the menu. Takes precedence over ID.
+	Name string `json:"name,omitempty"`
+
+	ManifestLanguage
+
+	// Extended selects the MENUEX layout. PE only.
+	Extended bool   `json:"extended,omitempty"`
+	HelpID   uint32 `json:"help_id,omitempty"`
+
+	Items []ManifestMenuItem `json:"items"`
+}
+
+// ManifestMenuItem describes a menu item in a Manifest. Items with child
+// items are popup menus.
+type ManifestMenuItem struct {
+	Text      string `json:"text,omitempty"`
+	ID        uint32 `json:"id,omitempty"`
+	Separator bool   `json:"separator,omitempty"`
+
+	// Grayed and Checked set the corresponding flags or states.
+	Grayed  bool `json:"grayed,omitempty"`
+	Checked bool `json:"checked,omitempty"`
+
+	// Flags, Type and State are combined with the above.
+	Flags  uint16 `json:"flags,omitempty"`
+	Type   uint32 `json:"type,omitempty"`
+	State  uint32 `json:"state,omitempty"`
+	HelpID uint32 `json:"help_id,omitempty"`
+
+	Items []ManifestMenuItem `json:"items,omitempty"`
+}
+
+// ManifestAccelerators describes an accelerator table in a Manifest.
+type ManifestAccelerators struct {
+	// ID is the integer ID of the table. If neither ID nor Name are given,
+	// the tables are numbered sequentially starting from 1.
+	ID uint16 `json:"id,omitempty"`
+
+	// Name is the string name of the table. Takes precedence over ID.
+	Name string `json:"name,omitempty"`
+
+	ManifestLanguage
+
+	Keys []ManifestAccelerator `json:"keys"`
+}
+
+// ManifestAccelerator describes a keyboard shortcut in a Manifest.
+type ManifestAccelerator struct {
+	// Key is a shortcut such as "Ctrl+S", "Shift+F5" or "a".
+	Key string `json:"key"`
+	ID  uint16 `json:"id"`
+
+	// NoInvert keeps the menu bar from being highlighted.
+	NoInvert bool `json:"no_invert,omitempty"`
+}
+
+// ManifestFont describes a raster font in a Manifest.
+type ManifestFont struct {
+	// ID is the integer ID of the font. If not given, the fonts are numbered
+	// sequentially starting from 1.
+	ID uint16 `json:"id,omitempty"`
+
+	ManifestLanguage
+
+	Face      string `json:"face"`
+	Copyright string `json:"copyright,omitempty"`
+
+	// Image is the path of a PNG image holding a strip of glyphs, each
+	// CellWidth pixels wide, drawn dark on a light or transparent
+	// background.
+	Image     string `json:"image"`
+	CellWidth int    `json:"cell_width"`
+
+	// FirstChar is the character of the first glyph. Defaults to 32 (space).
+	// DefaultChar and BreakChar default to FirstChar.
+	FirstChar   byte `json:"first_char,omitempty"`
+	DefaultChar byte `json:"default_char,omitempty"`
+	BreakChar   byte `json:"break_char,omitempty"`
+
+	// Points and Ascent are derived from the image height if zero.
+	Points uint16 `json:"points,omitempty"`
+	Ascent uint16 `json:"ascent,omitempty"`
+
+	Weight    uint16 `json:"weight,omitempty"`
+	Italic    bool   `json:"italic,omitempty"`
+	Underline bool   `json:"underline,omitempty"`
+	StrikeOut bool   `json:"strike_out,omitempty"`
+	Charset   uint8  `json:"charset,omitempty"`
+
+	// Family is the font family, such as 0x20 (FF_SWISS).
+	Family uint8 `json:"family,omitempty"`
+}
+
+// ManifestResource describes a resource in a Manifest whose data is stored
+// as-is.
+type ManifestResource struct {
+	// Type is the resource type: the name of a standard type, such as
+	// RCDATA, an integer ID such as "#10", or a custom type name.
+	Type string `json:"type"`
+
+	// ID is the integer ID of the resource.
+	ID uint16 `json:"id,omitempty"`
+
+	// Name is the string name of the resource. Takes precedence over ID.
+	Name string `json:"name,omitempty"`
+
+	ManifestLanguage
+
+	// File is the path of the file containing the resource data.
+	File string `json:"file,omitempty"`
+
+	// Data is the resource data, as text. Mutually exclusive with File.
+	Data *string `json:"data,omitempty"`
+}
+
+// ManifestImage describes a single icon image in a Manifest.
+type ManifestImage struct {
+	// Image is the path of the PNG icon image.
+	Image string `json:"image,omitempty"`
+
+	// Mask is the path of the PNG mask image. If empty, the mask is derived
+	// from the alpha channel of the icon image.
+	Mask string `json:"mask,omitempty"`
+
+	// BPP is the bit depth of the icon image. If zero, the depth of the
+	// image is used.
+	BPP int `json:"bpp,omitempty"`
+
+	// PNG stores the image file as-is as a PNG stream, rather than
+	// converting it to a DIB. The mask is not used.
+	PNG bool `json:"png,omitempty"`
+
+	// Hotspot is the hotspot of cursor images, as [x, y].
+	Hotspot [2]int `json:"hotspot,omitempty"`
+}
+
+// ManifestVersion describes the version information in a Manifest.
+type ManifestVersion struct {
+	ManifestLanguage
+
+	// FileVersion and ProductVersion are dotted version numbers, such as
+	// "1.2.3.4".
+	FileVersion    string `json:"file_version,omitempty"`
+	ProductVersion string `json:"product_version,omitempty"`
+
+	FileFlagsMask uint32 `json:"file_flags_mask,omitempty"`
+	FileFlags     uint32 `json:"file_flags,omitempty"`
+	FileOS        uint32 `json:"file_os,omitempty"`
+	FileType      uint32 `json:"file_type,omitempty"`
+	FileSubtype   uint32 `json:"file_subtype,omitempty"`
+
+	// Strings lists a string table for each translation.
+	Strings []ManifestVersionStrings `json:"strings,omitempty"`
+
+	// Translations overrides the list of translations in VarFileInfo, which
+	// otherwise has one for each string table.
+	Translations []ManifestTranslation `json:"translations,omitempty"`
+}
+
+// ManifestTranslation identifies the language and codepage of version
+// strings in a Manifest. If Codepage is zero, 1200 (Unicode) is used in PE
+// executables and the ANSI codepage of the language in NE executables.
+type ManifestTranslation struct {
+	Language uint16 `json:"language"`
+	Codepage uint16 `json:"codepage,omitempty"`
+}
+
+// ManifestVersionStrings is a version string table in a Manifest. The
+// strings are stored sorted by key.
+type ManifestVersionStrings struct {
+	ManifestTranslation
+	Values map[string]string `json:"values"`
+}
+
+// ManifestProgram describes the code of a runnable executable in a
+// Manifest.
+type ManifestProgram struct {
+	// ExitCode is the exit code of the process.
+	ExitCode uint32 `json:"exit_code,omitempty"`
+}
+
+// ManifestImport describes a DLL imported by an executable in a Manifest.
+type ManifestImport struct {
+	DLL       string                   `json:"dll"`
+	Functions []ManifestImportFunction `json:"functions"`
+}
+
+// ManifestImportFunction describes an imported function in a Manifest.
+type ManifestImportFunction struct {
+	// Name is the name of the function. If empty, the function is imported
+	// by Ordinal instead.
+	Name    string `json:"name,omitempty"`
+	Hint    uint16 `json:"hint,omitempty"`
+	Ordinal uint16 `json:"ordinal,omitempty"`
+}
+
+// ManifestExport describes an exported function in a Manifest.
+type ManifestExport struct {
+	// Name is the name of the export. If empty, the function is only
+	// exported by Ordinal.
+	Name string `json:"name,omitempty"`
+
+	// Ordinal is the ordinal of the export. If zero, the lowest unused
+	// ordinal is assigned.
+	Ordinal uint16 `json:"ordinal,omitempty"`
+
+	// Forwarder forwards the export to a function of another DLL, such as
+	// NTDLL.RtlFoo. Optional.
+	Forwarder string `json:"forwarder,omitempty"`
+}
+
+// ManifestTLS describes the thread local storage of an executable in a
+// Manifest.
+type ManifestTLS struct {
+	// Data is the initial value of the TLS data, stored as-is. Optional.
+	Data string `json:"data,omitempty"`
+
+	// ZeroFill is the number of zero bytes that follow Data.
+	ZeroFill uint32 `json:"zero_fill,omitempty"`
+
+	// Callbacks is the number of TLS callbacks, which may be 0.
+	Callbacks int `json:"callbacks,omitempty"`
+}
+
+// ManifestAppManifest describes an application manifest in a Manifest.
+// Either File is given, or the manifest is generated from the other fields.
+type ManifestAppManifest struct {
+	// ID is the resource ID. Defaults to 1, or 2 in DLLs.
+	ID uint16 `json:"id,omitempty"`
+
+	ManifestLanguage
+
+	// File is the path of an XML manifest to embed as-is.
+	File string `json:"file,omitempty"`
+
+	ExecutionLevel string   `json:"execution_level,omitempty"`
+	UIAccess       bool     `json:"ui_access,omitempty"`
+	DPIAware       string   `json:"dpi_aware,omitempty"`
+	DPIAwareness   string   `json:"dpi_awareness,omitempty"`
+	LongPathAware  bool     `json:"long_path_aware,omitempty"`
+	SupportedOS    []string `json:"supported_os,omitempty"`
+}
+
+// ManifestStringTable describes a string table in a Manifest.
+type ManifestStringTable struct {
+	ManifestLanguage
+
+	// Values maps string IDs to strings.
+	Values map[uint16]string `json:"values"`
+}
+
+// ManifestMessageTable is a message table in a Manifest.
+type ManifestMessageTable struct {
+	// ID is the integer ID of the message table. Defaults to 1.
+	ID uint16 `json:"id,omitempty"`
+
+	// Name is the string name of the message table. Takes precedence over
+	// ID.
+	Name string `json:"name,omitempty"`
+
+	ManifestLanguage
+
+	// ANSI stores the messages as ANSI rather than UTF-16 strings.
+	ANSI bool `json:"ansi,omitempty"`
+
+	// Values maps message IDs to messages. IDs may be given in hexadecimal
+	// with a 0x prefix.
+	Values map[string]string `json:"values"`
+}
+
+func (m *ManifestMessageTable) table() (*MessageTable, error) {
+	table := &MessageTable{
+		Name:             normalizeResourceName(ResourceName{ID: m.ID, Name: m.Name}),
+		ResourceLanguage: m.resourceLanguage(),
+		ANSI:             m.ANSI,
+		Messages:         map[uint32]string{},
+	}
+	if len(m.Values) == 0 {
+		return nil, errors.New("no messages")
+	}
+	for key, value := range m.Values {
+		id, err := strconv.ParseUint(key, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message ID %q", key)
+		}
+		table.Messages[uint32(id)] = value
+	}
+	return table, nil
+}
+
+func loadManifest(name string) (*Manifest, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	manifest := &Manifest{}
+	if err := dec.Decode(manifest); err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", name, err)
+	}
+	return manifest, nil
+}
+
+func build(args []string) {
+	flags := flag.NewFlagSet("make-mock-exe build", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usageError(flags, "expected exactly one manifest")
+	}
+
+	manifest, err := loadManifest(flags.Arg(0))
+	must(err, "loading manifest")
+
+	dir := filepath.Dir(flags.Arg(0))
+	failed := 0
+	for i, output := range manifest.Outputs {
+		if err := output.build(dir); err != nil {
+			name := output.Output
+			if name == "" {
+				name = fmt.Sprintf("outputs[%d]", i)
+			}
+			log.Printf("%s: %v", name, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		log.Fatalf("%d of %d outputs failed", failed, len(manifest.Outputs))
+	}
+}
+
+func (o *ManifestOutput) build(dir string) error {
+	if o.Output == "" {
+		return errors.New("missing output path")
+	}
+	exeFormat, err := parseEXEFormat(o.Format)
+	if err != nil {
+		return err
+	}
+
+	icons := o.Icons
+	if !o.ManifestIcon.empty() {
+		if len(icons) != 0 {
+			return errors.New("icons cannot be combined with an inline icon")
+		}
+		icons = []ManifestIcon{o.ManifestIcon}
+	}
+	if len(icons) == 0 && len(o.Cursors) == 0 && len(o.AnimatedCursors) == 0 && len(o.Bitmaps) == 0 && len(o.Dialogs) == 0 && len(o.Menus) == 0 && len(o.Accelerators) == 0 && len(o.Fonts) == 0 && len(o.Resources) == 0 && o.Version == nil && o.AppManifest == nil && len(o.Strings) == 0 && len(o.MessageTables) == 0 &&
+		o.Program == nil && len(o.Imports) == 0 && len(o.Exports) == 0 && o.TLS == nil {
+		return errors.New("output has no resources or code")
+	}
+	groups := []*IconGroup{}
+	for i, icon := range icons {
+		sources, err := icon.sources(dir)
+		if err != nil {
+			return fmt.Errorf("icon %d: %w", i+1, err)
+		}
+		group, err := NewIconGroup(icon.name(i), sources)
+		if err != nil {
+			return fmt.Errorf("icon %d: %w", i+1, err)
+		}
+		group.ResourceLanguage = icon.resourceLanguage()
+		groups = append(groups, group)
+	}
+	cursors := []*CursorGroup{}
+	for i, cursor := range o.Cursors {
+		sources, err := cursor.sources(dir)
+		if err != nil {
+			return fmt.Errorf("cursor %d: %w", i+1, err)
+		}
+		group, err := NewCursorGroup(cursor.name(i), sources)
+		if err != nil {
+			return fmt.Errorf("cursor %d: %w", i+1, err)
+		}
+		group.ResourceLanguage = cursor.resourceLanguage()
+		cursors = append(cursors, group)
+	}
+
+	animations := []*AnimatedCursor{}
+	for i, ani := range o.AnimatedCursors {
+		animation, err := ani.animation(dir, i)
+		if err != nil {
+			return fmt.Errorf("animated cursor %d: %w", i+1, err)
+		}
+		animations = append(animations, animation)
+	}
+
+	bitmaps := []*Bitmap{}
+	for i, bmp := range o.Bitmaps {
+		bitmap, err := bmp.bitmap(dir, i)
+		if err != nil {
+			return fmt.Errorf("bitmap %d: %w", i+1, err)
+		}
+		bitmaps = append(bitmaps, bitmap)
+	}
+
+	res := &Resources{Icons: groups, Cursors: cursors, AnimatedCursors: animations, Bitmaps: bitmaps}
+	for i, d := range o.Dialogs {
+		dialog, err := d.dialog(i)
+		if err != nil {
+			return fmt.Errorf("dialog %d: %w", i+1, err)
+		}
+		res.Dialogs = append(res.Dialogs, dialog)
+	}
+	for i, f := range o.Fonts {
+		font, err := f.font(dir, i)
+		if err != nil {
+			return fmt.Errorf("font %d: %w", i+1, err)
+		}
+		res.Fonts = append(res.Fonts, font)
+	}
+	res.Description, res.ModuleName, res.Library = o.Description, o.ModuleName, o.Library
+	res.DynamicBase, res.HighEntropyVA, res.NXCompat = o.DynamicBase, o.HighEntropyVA, o.NXCompat
+	if o.Program != nil {
+		res.Program = &Program{ExitCode: o.Program.ExitCode}
+	}
+	for _, imp := range o.Imports {
+		functions := []ImportFunction{}
+		for _, fn := range imp.Functions {
+			functions = append(functions, ImportFunction{Name: fn.Name, Hint: fn.Hint, Ordinal: fn.Ordinal})
+		}
+		res.Imports = append(res.Imports, Import{DLL: imp.DLL, Functions: functions})
+	}
+	if o.TLS != nil {
+		res.TLS = &TLS{Data: []byte(o.TLS.Data), ZeroFill: o.TLS.ZeroFill, Callbacks: o.TLS.Callbacks}
+	}
+	for _, export := range o.Exports {
+		res.Exports = append(res.Exports, Export{Name: export.Name, Ordinal: export.Ordinal, Forwarder: export.Forwarder})
+	}
+	if res.ModuleName == "" {
+		res.ModuleName = defaultModuleName(o.Output, exeFormat)
+	}
+	for i, m := range o.Menus {
+		menu, err := m.menu(i)
+		if err != nil {
+			return fmt.Errorf("menu %d: %w", i+1, err)
+		}
+		res.Menus = append(res.Menus, menu)
+	}
+	for i, a := range o.Accelerators {
+		table, err := a.table(i)
+		if err != nil {
+			return fmt.Errorf("accelerators %d: %w", i+1, err)
+		}
+		res.Accelerators = append(res.Accelerators, table)
+	}
+	for i, r := range o.Resources {
+		raw, err := r.resource(dir)
+		if err != nil {
+			return fmt.Errorf("resource %d: %w", i+1, err)
+		}
+		res.Raw = append(res.Raw, raw)
+	}
+	if o.Version != nil {
+		if res.Version, err = o.Version.info(); err != nil {
+			return fmt.Errorf("version: %w", err)
+		}
+	}
+	if o.AppManifest != nil {
+		if res.Manifest, err = o.AppManifest.appManifest(dir); err != nil {
+			return fmt.Errorf("app_manifest: %w", err)
+		}
+	}
+	for i, table := range o.Strings {
+		if len(table.Values) == 0 {
+			return fmt.Errorf("string table %d: no strings", i+1)
+		}
+		t := &StringTable{ResourceLanguage: table.resourceLanguage(), Strings: table.Values}
+		res.Strings = append(res.Strings, t)
+	}
+	for i, m := range o.MessageTables {
+		table, err := m.table()
+		if err != nil {
+			return fmt.Errorf("message table %d: %w", i+1, err)
+		}
+		res.MessageTables = append(res.MessageTables, table)
+	}
+
+	var exeBuf bytes.Buffer
+	if err := png2exe(&exeBuf, res, exeFormat); err != nil {
+		return err
+	}
+	if err := writeOutput(resolvePath(dir, o.Output), exeBuf.Bytes()); err != nil {
+		return err
+	}
+	for i, icon := range icons {
+		if icon.ICO == "" {
+			continue
+		}
+		var icoBuf bytes.Buffer
+		groups[i].WriteICO(&icoBuf)
+		if err := writeOutput(resolvePath(dir, icon.ICO), icoBuf.Bytes()); err != nil {
+			return err
+		}
+	}
+	for i, cursor := range o.Cursors {
+		if cursor.CUR == "" {
+			continue
+		}
+		var curBuf bytes.Buffer
+		cursors[i].WriteCUR(&curBuf)
+		if err := writeOutput(resolvePath(dir, cursor.CUR), curBuf.Bytes()); err != nil {
+			return err
+		}
+	}
+	for i, ani := range o.AnimatedCursors {
+		if ani.ANI == "" {
+			continue
+		}
+		var aniBuf bytes.Buffer
+		if err := animations[i].WriteANI(&aniBuf); err != nil {
+			return err
+		}
+		if err := writeOutput(resolvePath(dir, ani.ANI), aniBuf.Bytes()); err != nil {
+			return err
+		}
+	}
+	for i, bmp := range o.Bitmaps {
+		if bmp.BMP == "" {
+			continue
+		}
+		var bmpBuf bytes.Buffer
+		if err := bitmaps[i].DIB.WriteBMP(&bmpBuf); err != nil {
+			return fmt.Errorf("bitmap %d: %w", i+1, err)
+		}
+		if err := writeOutput(resolvePath(dir, bmp.BMP), bmpBuf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *ManifestIcon) empty() bool {
+	return m.ID == 0 && m.Name == "" && m.Language == nil && m.Codepage == 0 && m.ICO == "" && m.ManifestImage == (ManifestImage{}) && len(m.Images) == 0
+}
+
+func (m *ManifestGroup) name(index int) ResourceName {
+	name := ResourceName{ID: m.ID, Name: m.Name}
+	if name == (ResourceName{}) {
+		name.ID = uint16(index + 1)
+	}
+	return name
+}
+
+func (m *ManifestGroup) sources(dir string) ([]IconSource, error) {
+	images := m.Images
+	if m.ManifestImage != (ManifestImage{}) {
+		if len(images) != 0 {
+			return nil, errors.New("image and images are mutually exclusive")
+		}
+		images = []ManifestImage{m.ManifestImage}
+	}
+	if len(images) == 0 {
+		return nil, errors.New("missing image path")
+	}
+	sources := []IconSource{}
+	for _, img := range images {
+		source, err := img.source(dir)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+func (m *ManifestAnimatedCursor) animation(dir string, index int) (*AnimatedCursor, error) {
+	animation := &AnimatedCursor{
+		Name:             ResourceName{ID: m.ID, Name: m.Name},
+		ResourceLanguage: m.resourceLanguage(),
+		Icon:             m.Icon,
+		DisplayRate:      m.Rate,
+		Rates:            m.Rates,
+		Sequence:         m.Sequence,
+		Title:            m.Title,
+		Artist:           m.Artist,
+	}
+	if animation.Name == (ResourceName{}) {
+		animation.Name.ID = uint16(index + 1)
+	}
+	animation.Name = normalizeResourceName(animation.Name)
+	if len(m.Frames) == 0 {
+		return nil, errors.New("missing frames")
+	}
+	for i, img := range m.Frames {
+		source, err := img.source(dir)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i+1, err)
+		}
+		frame, err := NewCursorGroup(ResourceName{}, []IconSource{source})
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i+1, err)
+		}
+		animation.Frames = append(animation.Frames, frame)
+	}
+	return animation, animation.check()
+}
+
+func (m *ManifestBitmap) bitmap(dir string, index int) (*Bitmap, error) {
+	if m.Image == "" {
+		return nil, errors.New("missing image path")
+	}
+	if !validBPP(m.BPP) {
+		return nil, fmt.Errorf("unsupported bit depth %d", m.BPP)
+	}
+	img, err := loadPNGFile(resolvePath(dir, m.Image))
+	if err != nil {
+		return nil, err
+	}
+	name := ResourceName{ID: m.ID, Name: m.Name}
+	if name == (ResourceName{}) {
+		name.ID = uint16(index + 1)
+	}
+	bitmap, err := NewBitmap(name, img, m.BPP)
+	if err != nil {
+		return nil, err
+	}
+	bitmap.ResourceLanguage = m.resourceLanguage()
+	return bitmap, nil
+}
+
+func (m *ManifestDialog) dialog(index int) (*Dialog, error) {
+	dialog := &Dialog{
+		Name:             ResourceName{ID: m.ID, Name: m.Name},
+		ResourceLanguage: m.resourceLanguage(),
+		Extended:         m.Extended,
+		Style:            m.Style,
+		ExStyle:          m.ExStyle,
+		HelpID:           m.HelpID,
+		X:                m.X,
+		Y:                m.Y,
+		Width:            m.Width,
+		Height:           m.Height,
+		Title:            m.Title,
+	}
+	if dialog.Name == (ResourceName{}) {
+		dialog.Name.ID = uint16(index + 1)
+	}
+	dialog.Name = normalizeResourceName(dialog.Name)
+	if dialog.Style == 0 {
+		dialog.Style = WindowStylePopup | WindowStyleBorder | WindowStyleSysMenu
+	}
+	var err error
+	if m.Menu != "" {
+		if dialog.Menu, err = ParseResourceName(m.Menu); err != nil {
+			return nil, err
+		}
+	}
+	if m.Class != "" {
+		if dialog.Class, err = ParseResourceName(m.Class); err != nil {
+			return nil, err
+		}
+	}
+	if m.Font != nil {
+		dialog.Font = &DialogFont{
+			PointSize: m.Font.Size,
+			Name:      m.Font.Name,
+			Weight:    m.Font.Weight,
+			Italic:    m.Font.Italic,
+			Charset:   m.Font.Charset,
+		}
+	}
+	for i, c := range m.Controls {
+		if c.Class == "" {
+			return nil, fmt.Errorf("control %d: missing class", i+1)
+		}
+		control := DialogControl{
+			ID:      c.ID,
+			Class:   ParseDialogClass(c.Class),
+			Text:    ResourceName{Name: c.Text},
+			Style:   WindowStyleChild | WindowStyleVisible | c.Style,
+			ExStyle: c.ExStyle,
+			HelpID:  c.HelpID,
+			X:       c.X,
+			Y:       c.Y,
+			Width:   c.Width,
+			Height:  c.Height,
+		}
+		if c.TextID != 0 {
+			if c.Text != "" {
+				return nil, fmt.Errorf("control %d: text and text_id are mutually exclusive", i+1)
+			}
+			control.Text = ResourceName{ID: c.TextID}
+		}
+		dialog.Controls = append(dialog.Controls, control)
+	}
+	return dialog, nil
+}
+
+func (m *ManifestFont) font(dir string, index int) (*Font, error) {
+	if m.Image == "" {
+		return nil, errors.New("missing image path")
+	}
+	img, err := loadPNGFile(resolvePath(dir, m.Image))
+	if err != nil {
+		return nil, err
+	}
+	id := m.ID
+	if id == 0 {
+		id = uint16(index + 1)
+	}
+	firstChar := m.FirstChar
+	if firstChar == 0 {
+		firstChar = ' '
+	}
+	font, err := NewFontFromStrip(ResourceName{ID: id}, m.Face, img, firstChar, m.CellWidth)
+	if err != nil {
+		return nil, err
+	}
+	font.ResourceLanguage = m.resourceLanguage()
+	font.Copyright = m.Copyright
+	if m.DefaultChar != 0 {
+		font.DefaultChar = m.DefaultChar
+	}
+	if m.BreakChar != 0 {
+		font.BreakChar = m.BreakChar
+	}
+	font.Points, font.Ascent = m.Points, m.Ascent
+	font.Weight, font.Italic, font.Underline, font.StrikeOut = m.Weight, m.Italic, m.Underline, m.StrikeOut
+	font.Charset, font.Family = m.Charset, m.Family
+	return font, nil
+}
+
+func (m *ManifestMenu) menu(index int) (*Menu, error) {
+	if len(m.Items) == 0 {
+		return nil, errors.New("missing items")
+	}
+	menu := &Menu{
+		Name:             ResourceName{ID: m.ID, Name: m.Name},
+		ResourceLanguage: m.resourceLanguage(),
+		Extended:         m.Extended,
+		HelpID:           m.HelpID,
+		Items:            menuItems(m.Items),
+	}
+	if menu.Name == (ResourceName{}) {
+		menu.Name.ID = uint16(index + 1)
+	}
+	menu.Name = normalizeResourceName(menu.Name)
+	return menu, nil
+}
+
+func menuItems(items []ManifestMenuItem) []MenuItem {
+	result := []MenuItem{}
+	for _, m := range items {
+		item := MenuItem{
+			Text:      m.Text,
+			ID:        m.ID,
+			Separator: m.Separator,
+			Flags:     m.Flags,
+			Type:      m.Type,
+			State:     m.State,
+			HelpID:    m.HelpID,
+		}
+		if m.Grayed {
+			item.Flags |= MenuFlagGrayed
+			item.State |= MenuStateGrayed
+		}
+		if m.Checked {
+			item.Flags |= MenuFlagChecked
+			item.State |= MenuStateChecked
+		}
+		if len(m.Items) != 0 {
+			item.Items = menuItems(m.Items)
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+func (m *ManifestAccelerators) table(index int) (*AcceleratorTable, error) {
+	if len(m.Keys) == 0 {
+		return nil, errors.New("missing keys")
+	}
+	table := &AcceleratorTable{
+		Name:             ResourceName{ID: m.ID, Name: m.Name},
+		ResourceLanguage: m.resourceLanguage(),
+	}
+	if table.Name == (ResourceName{}) {
+		table.Name.ID = uint16(index + 1)
+	}
+	table.Name = normalizeResourceName(table.Name)
+	for i, k := range m.Keys {
+		key, flags, err := ParseAccelerator(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i+1, err)
+		}
+		if k.NoInvert {
+			flags |= AcceleratorNoInvert
+		}
+		table.Entries = append(table.Entries, Accelerator{Key: key, Flags: flags, ID: k.ID})
+	}
+	return table, nil
+}
+
+func (m *ManifestResource) resource(dir string) (*RawResource, error) {
+	typ, err := ParseResourceType(m.Type)
+	if err != nil {
+		return nil, err
+	}
+	name := ResourceName{ID: m.ID, Name: m.Name}
+	if name == (ResourceName{}) {
+		return nil, errors.New("missing id or name")
+	}
+	var raw *RawResource
+	switch {
+	case m.File != "" && m.Data != nil:
+		return nil, errors.New("file and data are mutually exclusive")
+	case m.File != "":
+		if raw, err = LoadRawResource(typ, name, resolvePath(dir, m.File)); err != nil {
+			return nil, err
+		}
+	case m.Data != nil:
+		if *m.Data == "" {
+			return nil, errors.New("empty data")
+		}
+		raw = NewRawResource(typ, name, []byte(*m.Data))
+	default:
+		return nil, errors.New("missing file or data")
+	}
+	raw.ResourceLanguage = m.resourceLanguage()
+	return raw, nil
+}
+
+func (m ManifestImage) source(dir string) (IconSource, error) {
+	if m.Image == "" {
+		return IconSource{}, errors.New("missing image path")
+	}
+	if !validBPP(m.BPP) {
+		return IconSource{}, fmt.Errorf("unsupported bit depth %d", m.BPP)
+	}
+	if m.PNG {
+		if m.Mask != "" {
+			return IconSource{}, errors.New("png images cannot have a mask")
+		}
+		data, err := os.ReadFile(resolvePath(dir, m.Image))
+		if err != nil {
+			return IconSource{}, err
+		}
+		return IconSource{PNG: data, BPP: m.BPP, Hotspot: image.Pt(m.Hotspot[0], m.Hotspot[1])}, nil
+	}
+	img, err := loadPNGFile(resolvePath(dir, m.Image))
+	if err != nil {
+		return IconSource{}, err
+	}
+	var mask image.Image = alphaMask{img}
+	if m.Mask != "" {
+		if mask, err = loadPNGFile(resolvePath(dir, m.Mask)); err != nil {
+			return IconSource{}, err
+		}
+	}
+	return IconSource{Image: img, Mask: mask, BPP: m.BPP, Hotspot: image.Pt(m.Hotspot[0], m.Hotspot[1])}, nil
+}
+
+func (m *ManifestVersion) info() (*VersionInfo, error) {
+	info := &VersionInfo{
+		ResourceLanguage: m.resourceLanguage(),
+		FileFlagsMask:    m.FileFlagsMask,
+		FileFlags:        m.FileFlags,
+		FileOS:           m.FileOS,
+		FileType:         m.FileType,
+		FileSubtype:      m.FileSubtype,
+	}
+	var err error
+	if m.FileVersion != "" {
+		if info.FileVersion, err = ParseVersion(m.FileVersion); err != nil {
+			return nil, err
+		}
+	}
+	info.ProductVersion = info.FileVersion
+	if m.ProductVersion != "" {
+		if info.ProductVersion, err = ParseVersion(m.ProductVersion); err != nil {
+			return nil, err
+		}
+	}
+	for _, table := range m.Strings {
+		keys := make([]string, 0, len(table.Values))
+		for key := range table.Values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		strings := []VersionString{}
+		for _, key := range keys {
+			strings = append(strings, VersionString{Key: key, Value: table.Values[key]})
+		}
+		info.StringTables = append(info.StringTables, VersionStringTable{
+			VersionTranslation: VersionTranslation(table.ManifestTranslation),
+			Strings:            strings,
+		})
+	}
+	for _, t := range m.Translations {
+		info.Translations = append(info.Translations, VersionTranslation(t))
+	}
+	return info, nil
+}
+
+func (m *ManifestAppManifest) appManifest(dir string) (*AppManifest, error) {
+	manifest := &AppManifest{
+		ID:               m.ID,
+		ResourceLanguage: m.resourceLanguage(),
+		ExecutionLevel:   m.ExecutionLevel,
+		UIAccess:         m.UIAccess,
+		DPIAware:         m.DPIAware,
+		DPIAwareness:     m.DPIAwareness,
+		LongPathAware:    m.LongPathAware,
+		SupportedOS:      m.SupportedOS,
+	}
+	if m.File != "" {
+		if m.ExecutionLevel != "" || m.UIAccess || m.DPIAware != "" || m.DPIAwareness != "" || m.LongPathAware || len(m.SupportedOS) != 0 {
+			return nil, errors.New("file cannot be combined with generated settings")
+		}
+		data, err := os.ReadFile(resolvePath(dir, m.File))
+		if err != nil {
+			return nil, err
+		}
+		manifest.XML = data
+	}
+	return manifest, nil
+}
+
+func resolvePath(dir, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+func writeOutput(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o777); err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, 0o666)
+}
@@ -0,0 +1,144 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testManifest = `{"outputs": [
+	{
+		"output": "lib/mock.dll",
+		"format": "pe32plus",
+		"library": true,
+		"image": "icon.png",
+		"ico": "icon.ico",
+		"exports": [{"name": "Foo"}],
+		"version": {"file_version": "1.2"}
+	},
+	{
+		"output": "code.exe",
+		"format": "pe32",
+		"program": {"exit_code": 7},
+		"imports": [{"dll": "KERNEL32.dll", "functions": [{"name": "ExitProcess"}]}]
+	},
+	{
+		"output": "empty.exe",
+		"format": "pe32"
+	}
+]}`
+
+func TestManifestBuild(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 16), G: uint8(y * 16), A: 0xff})
+		}
+	}
+	pngBuf := bytes.Buffer{}
+	if err := png.Encode(&pngBuf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "icon.png"), pngBuf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	manifestPath := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(manifestPath, []byte(testManifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := loadManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Outputs) != 3 {
+		t.Fatalf("%d outputs, want 3", len(manifest.Outputs))
+	}
+	for i := range manifest.Outputs[:2] {
+		if err := manifest.Outputs[i].build(dir); err != nil {
+			t.Fatalf("%s: %v", manifest.Outputs[i].Output, err)
+		}
+	}
+	err = manifest.Outputs[2].build(dir)
+	if err == nil || err.Error() != "output has no resources or code" {
+		t.Errorf("empty output: error %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "empty.exe")); err == nil {
+		t.Error("empty output was written")
+	}
+
+	dll, err := pe.Open(filepath.Join(dir, "lib", "mock.dll"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dll.Close()
+	if base, _ := imageBase(dll); base != PE32PlusDLLImageBase {
+		t.Errorf("mock.dll: image base %#x", base)
+	}
+	for _, name := range []string{".text", ".edata", ".rsrc", ".reloc"} {
+		if dll.Section(name) == nil {
+			t.Errorf("mock.dll: missing %s section", name)
+		}
+	}
+	ico, err := os.ReadFile(filepath.Join(dir, "icon.ico"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(ico, []byte{0, 0, 1, 0, 1, 0}) {
+		t.Errorf("icon.ico: header % x", ico[:6])
+	}
+
+	exe, err := pe.Open(filepath.Join(dir, "code.exe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exe.Close()
+	if exe.Section(".rsrc") != nil {
+		t.Error("code.exe: unexpected .rsrc section")
+	}
+	symbols, err := exe.ImportedSymbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ExitProcess:KERNEL32.dll"}; !reflect.DeepEqual(symbols, want) {
+		t.Errorf("code.exe: imported symbols %q, want %q", symbols, want)
+	}
+}
+
+func TestManifestEmptyEntries(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "empty.bin"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct{ output, err string }{
+		{`{"output": "a.exe", "format": "pe32", "strings": [{"values": {}}]}`, "string table 1: no strings"},
+		{`{"output": "a.exe", "format": "pe32", "message_tables": [{"values": {}}]}`, "message table 1: no messages"},
+		{`{"output": "a.exe", "format": "pe32", "resources": [{"type": "RCDATA", "id": 1, "data": ""}]}`, "resource 1: empty data"},
+		{`{"output": "a.exe", "format": "pe32", "resources": [{"type": "RCDATA", "id": 1, "file": "empty.bin"}]}`, "resource 1: " + filepath.Join(dir, "empty.bin") + " is empty"},
+	} {
+		output := ManifestOutput{}
+		if err := json.Unmarshal([]byte(test.output), &output); err != nil {
+			t.Fatal(err)
+		}
+		if err := output.build(dir); err == nil || err.Error() != test.err {
+			t.Errorf("%s: error %v, want %q", test.output, err, test.err)
+		}
+	}
+}
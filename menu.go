@@ -0,0 +1,304 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Enumeration of menu item flags, used in standard menus.
+const (
+	MenuFlagGrayed       = 0x0001
+	MenuFlagDisabled     = 0x0002
+	MenuFlagChecked      = 0x0008
+	MenuFlagPopup        = 0x0010
+	MenuFlagMenuBarBreak = 0x0020
+	MenuFlagMenuBreak    = 0x0040
+	MenuFlagEnd          = 0x0080
+	MenuFlagHelp         = 0x4000
+)
+
+// Enumeration of menu item types and states, used in extended menus.
+const (
+	MenuTypeString        = 0x0000
+	MenuTypeMenuBarBreak  = 0x0020
+	MenuTypeMenuBreak     = 0x0040
+	MenuTypeRadioCheck    = 0x0200
+	MenuTypeSeparator     = 0x0800
+	MenuTypeRightJustify  = 0x4000
+	MenuStateGrayed       = 0x0003
+	MenuStateChecked      = 0x0008
+	MenuStateHilite       = 0x0080
+	MenuStateDefault      = 0x1000
+	menuExResInfoPopup    = 0x01
+	menuExResInfoLastItem = 0x80
+)
+
+// Menu is a menu template, stored as a ResourceMenu resource.
+type Menu struct {
+	Name ResourceName
+
+	ResourceLanguage
+
+	// Extended selects the MENUEX layout, which adds item types and states,
+	// help IDs and 32-bit item IDs. It is only supported in PE executables.
+	Extended bool
+	HelpID   uint32
+
+	Items []MenuItem
+}
+
+// MenuItem is an item of a Menu. It is a popup menu if it has child items.
+type MenuItem struct {
+	Text string
+
+	// ID is the command ID. It must fit in 16 bits, except in extended
+	// menus. Popup menus only have IDs in extended menus.
+	ID uint32
+
+	// Separator makes the item a separator; Text and ID are ignored.
+	Separator bool
+
+	// Flags are the item flags of standard menus. MenuFlagPopup and
+	// MenuFlagEnd are set automatically.
+	Flags uint16
+
+	// Type, State and HelpID are only stored in extended menus. HelpID is
+	// only stored for popup menus.
+	Type   uint32
+	State  uint32
+	HelpID uint32
+
+	Items []MenuItem
+}
+
+// Encode encodes the menu template. If unicode is true, the strings are
+// UTF-16, as in PE executables; otherwise, they are ANSI, as in NE
+// executables.
+func (m *Menu) Encode(unicode bool) ([]byte, error) {
+	if len(m.Items) == 0 {
+		return nil, errors.New("menu has no items")
+	}
+	le := binary.LittleEndian
+	buf := []byte{}
+	if m.Extended {
+		if !unicode {
+			return nil, errors.New("extended menus are not supported in NE executables")
+		}
+		buf = le.AppendUint16(buf, 1)
+		buf = le.AppendUint16(buf, 4)
+		buf = le.AppendUint32(buf, m.HelpID)
+		return appendMenuExItems(buf, m.Items), nil
+	}
+	buf = le.AppendUint16(buf, 0)
+	buf = le.AppendUint16(buf, 0)
+	return appendMenuItems(buf, m.Items, unicode, m.codepage())
+}
+
+// appendMenuItems appends menu items, with UTF-16 text if unicode is true,
+// and ANSI text in codepage otherwise.
+func appendMenuItems(buf []byte, items []MenuItem, unicode bool, codepage uint32) ([]byte, error) {
+	le := binary.LittleEndian
+	var err error
+	for i, item := range items {
+		flags := item.Flags &^ (MenuFlagPopup | MenuFlagEnd)
+		if len(item.Items) != 0 {
+			flags |= MenuFlagPopup
+		}
+		if i == len(items)-1 {
+			flags |= MenuFlagEnd
+		}
+		text := item.Text
+		if item.Separator {
+			flags &= MenuFlagEnd
+			text = ""
+		}
+		buf = le.AppendUint16(buf, flags)
+		if flags&MenuFlagPopup == 0 {
+			id := item.ID
+			if item.Separator {
+				id = 0
+			}
+			if id > 0xffff {
+				return nil, fmt.Errorf("menu item %q: ID %d does not fit in 16 bits", item.Text, id)
+			}
+			buf = le.AppendUint16(buf, uint16(id))
+		}
+		if unicode {
+			buf = append(buf, utf16z(text)...)
+		} else if buf, err = appendANSIZ(buf, text, codepage); err != nil {
+			return nil, fmt.Errorf("menu item %q: %w", item.Text, err)
+		}
+		if flags&MenuFlagPopup != 0 {
+			if buf, err = appendMenuItems(buf, item.Items, unicode, codepage); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf, nil
+}
+
+// appendMenuExItems appends extended menu items, each of which is aligned to
+// 32 bits.
+func appendMenuExItems(buf []byte, items []MenuItem) []byte {
+	le := binary.LittleEndian
+	for i, item := range items {
+		buf = pad32(buf)
+		typ, text := item.Type, item.Text
+		if item.Separator {
+			typ |= MenuTypeSeparator
+			text = ""
+		}
+		resInfo := uint16(0)
+		if len(item.Items) != 0 {
+			resInfo |= menuExResInfoPopup
+		}
+		if i == len(items)-1 {
+			resInfo |= menuExResInfoLastItem
+		}
+		buf = le.AppendUint32(buf, typ)
+		buf = le.AppendUint32(buf, item.State)
+		buf = le.AppendUint32(buf, item.ID)
+		buf = le.AppendUint16(buf, resInfo)
+		buf = append(buf, utf16z(text)...)
+		if len(item.Items) != 0 {
+			buf = pad32(buf)
+			buf = le.AppendUint32(buf, item.HelpID)
+			buf = appendMenuExItems(buf, item.Items)
+		}
+	}
+	return buf
+}
+
+// Enumeration of accelerator flags.
+const (
+	AcceleratorVirtKey  = 0x01
+	AcceleratorNoInvert = 0x02
+	AcceleratorShift    = 0x04
+	AcceleratorControl  = 0x08
+	AcceleratorAlt      = 0x10
+	acceleratorLast     = 0x80
+)
+
+// virtualKeyNames maps key names to virtual key codes. Letters and digits
+// are their own virtual key codes, and the punctuation keys below are the
+// OEM keys found on every layout.
+var virtualKeyNames = map[string]uint16{
+	"BACK":     0x08,
+	"TAB":      0x09,
+	"ENTER":    0x0d,
+	"ESCAPE":   0x1b,
+	"SPACE":    0x20,
+	"PAGEUP":   0x21,
+	"PAGEDOWN": 0x22,
+	"END":      0x23,
+	"HOME":     0x24,
+	"LEFT":     0x25,
+	"UP":       0x26,
+	"RIGHT":    0x27,
+	"DOWN":     0x28,
+	"INSERT":   0x2d,
+	"DELETE":   0x2e,
+	"+":        0xbb,
+	",":        0xbc,
+	"-":        0xbd,
+	".":        0xbe,
+}
+
+// AcceleratorTable is a table of keyboard shortcuts, stored as a
+// ResourceAccelerator resource.
+type AcceleratorTable struct {
+	Name ResourceName
+
+	ResourceLanguage
+
+	Entries []Accelerator
+}
+
+// Accelerator is a single keyboard shortcut.
+type Accelerator struct {
+	// Key is a virtual key code if Flags has AcceleratorVirtKey, and a
+	// character otherwise.
+	Key   uint16
+	Flags uint16
+	ID    uint16
+}
+
+// ParseAccelerator parses a keyboard shortcut such as "Ctrl+Shift+S" or
+// "F5". Shortcuts with modifiers or named keys use virtual key codes, while
+// a single character on its own stands for itself. The key may itself be
+// "+", as in "Ctrl++".
+func ParseAccelerator(s string) (key, flags uint16, err error) {
+	mods, name := "", s
+	if i := strings.LastIndex(strings.TrimSuffix(s, "+"), "+"); i >= 0 {
+		mods, name = s[:i], s[i+1:]
+	}
+	if mods != "" {
+		for _, mod := range strings.Split(mods, "+") {
+			switch strings.ToUpper(mod) {
+			case "CTRL":
+				flags |= AcceleratorControl
+			case "SHIFT":
+				flags |= AcceleratorShift
+			case "ALT":
+				flags |= AcceleratorAlt
+			default:
+				return 0, 0, fmt.Errorf("invalid modifier %q in %q", mod, s)
+			}
+		}
+	}
+	upper := strings.ToUpper(name)
+	switch {
+	case len(name) == 1 && flags == 0:
+		return uint16(name[0]), 0, nil
+	case len(name) == 1 && (upper[0] >= 'A' && upper[0] <= 'Z' || upper[0] >= '0' && upper[0] <= '9'):
+		return uint16(upper[0]), flags | AcceleratorVirtKey, nil
+	case len(upper) > 1 && upper[0] == 'F':
+		if n, err := strconv.Atoi(upper[1:]); err == nil && n >= 1 && n <= 24 {
+			return uint16(0x70 + n - 1), flags | AcceleratorVirtKey, nil
+		}
+	}
+	if vk, ok := virtualKeyNames[upper]; ok {
+		return vk, flags | AcceleratorVirtKey, nil
+	}
+	return 0, 0, fmt.Errorf("invalid key %q in %q", name, s)
+}
+
+// Encode encodes the accelerator table. If unicode is true, the 8-byte
+// entries of PE executables are produced; otherwise, the packed 5-byte
+// entries of NE executables are.
+func (t *AcceleratorTable) Encode(unicode bool) ([]byte, error) {
+	if len(t.Entries) == 0 {
+		return nil, errors.New("accelerator table has no entries")
+	}
+	le := binary.LittleEndian
+	buf := []byte{}
+	for i, entry := range t.Entries {
+		flags := entry.Flags &^ acceleratorLast
+		if i == len(t.Entries)-1 {
+			flags |= acceleratorLast
+		}
+		if unicode {
+			buf = le.AppendUint16(buf, flags)
+			buf = le.AppendUint16(buf, entry.Key)
+			buf = le.AppendUint16(buf, entry.ID)
+			buf = le.AppendUint16(buf, 0)
+		} else {
+			buf = append(buf, byte(flags))
+			buf = le.AppendUint16(buf, entry.Key)
+			buf = le.AppendUint16(buf, entry.ID)
+		}
+	}
+	return buf, nil
+}
@@ -0,0 +1,134 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func testMenu() *Menu {
+	return &Menu{
+		Items: []MenuItem{
+			{Text: "F", Items: []MenuItem{{Text: "O", ID: 100}, {Separator: true}}},
+			{Text: "H", ID: 200},
+		},
+	}
+}
+
+func TestMenuEncode(t *testing.T) {
+	for _, test := range []struct {
+		unicode, extended bool
+		want              string
+	}{
+		{false, false, `
+			00 00 00 00
+			10 00  46 00
+			00 00 64 00  4f 00
+			80 00 00 00  00
+			80 00 c8 00  48 00
+		`},
+		{true, false, `
+			00 00 00 00
+			10 00  46 00 00 00
+			00 00 64 00  4f 00 00 00
+			80 00 00 00  00 00
+			80 00 c8 00  48 00 00 00
+		`},
+		{true, true, `
+			01 00 04 00 00 00 00 00
+			00 00 00 00  00 00 00 00  00 00 00 00  01 00  46 00 00 00  00 00
+			00 00 00 00
+			00 00 00 00  00 00 00 00  64 00 00 00  00 00  4f 00 00 00  00 00
+			00 08 00 00  00 00 00 00  00 00 00 00  80 00  00 00
+			00 00 00 00  00 00 00 00  c8 00 00 00  80 00  48 00 00 00
+		`},
+	} {
+		m := testMenu()
+		m.Extended = test.extended
+		got, err := m.Encode(test.unicode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := unhex(t, test.want); !bytes.Equal(got, want) {
+			t.Errorf("Encode(%v), extended %v =\n%s\nwant\n%s", test.unicode, test.extended, hex.Dump(got), hex.Dump(want))
+		}
+	}
+}
+
+func TestMenuEncodeErrors(t *testing.T) {
+	m := testMenu()
+	m.Extended = true
+	if _, err := m.Encode(false); err == nil {
+		t.Error("Encode(false) of an extended menu succeeded")
+	}
+	m = testMenu()
+	m.Items[1].ID = 0x10000
+	if _, err := m.Encode(true); err == nil {
+		t.Error("Encode(true) succeeded with a 32-bit ID")
+	}
+	if _, err := (&Menu{}).Encode(true); err == nil {
+		t.Error("Encode(true) of an empty menu succeeded")
+	}
+}
+
+func TestParseAccelerator(t *testing.T) {
+	for _, test := range []struct {
+		s          string
+		key, flags uint16
+	}{
+		{"a", 'a', 0},
+		{"+", '+', 0},
+		{"Ctrl+S", 'S', AcceleratorVirtKey | AcceleratorControl},
+		{"ctrl+shift+s", 'S', AcceleratorVirtKey | AcceleratorControl | AcceleratorShift},
+		{"Alt+F4", 0x73, AcceleratorVirtKey | AcceleratorAlt},
+		{"F12", 0x7b, AcceleratorVirtKey},
+		{"Delete", 0x2e, AcceleratorVirtKey},
+		{"Ctrl++", 0xbb, AcceleratorVirtKey | AcceleratorControl},
+		{"Ctrl+-", 0xbd, AcceleratorVirtKey | AcceleratorControl},
+	} {
+		key, flags, err := ParseAccelerator(test.s)
+		if err != nil {
+			t.Errorf("ParseAccelerator(%q): %v", test.s, err)
+		} else if key != test.key || flags != test.flags {
+			t.Errorf("ParseAccelerator(%q) = %#x, %#x, want %#x, %#x", test.s, key, flags, test.key, test.flags)
+		}
+	}
+	for _, s := range []string{"", "Ctrl+", "Meta+S", "F25", "Ctrl+Nope"} {
+		if _, _, err := ParseAccelerator(s); err == nil {
+			t.Errorf("ParseAccelerator(%q) succeeded", s)
+		}
+	}
+}
+
+func TestAcceleratorTableEncode(t *testing.T) {
+	table := &AcceleratorTable{Entries: []Accelerator{
+		{Key: 'S', Flags: AcceleratorVirtKey | AcceleratorControl, ID: 101},
+		{Key: 'q', ID: 102},
+	}}
+	got, err := table.Encode(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := unhex(t, "09 00 53 00 65 00 00 00  80 00 71 00 66 00 00 00"); !bytes.Equal(got, want) {
+		t.Errorf("Encode(true) = % x, want % x", got, want)
+	}
+	got, err = table.Encode(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := unhex(t, "09 53 00 65 00  80 71 00 66 00"); !bytes.Equal(got, want) {
+		t.Errorf("Encode(false) = % x, want % x", got, want)
+	}
+	if _, err := (&AcceleratorTable{}).Encode(true); err == nil {
+		t.Error("Encode(true) of an empty table succeeded")
+	}
+}
@@ -0,0 +1,108 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Enumeration of message entry flags.
+const (
+	MessageEntryANSI    = 0x0000
+	MessageEntryUnicode = 0x0001
+)
+
+// MessageTable is a set of messages identified by 32-bit IDs, stored as a
+// ResourceMessageTable resource (MESSAGE_RESOURCE_DATA). Runs of
+// consecutive IDs are stored as blocks.
+type MessageTable struct {
+	// Name defaults to ID 1, which is the resource FormatMessage reads.
+	Name ResourceName
+
+	ResourceLanguage
+
+	// ANSI stores the messages as ANSI rather than UTF-16 strings.
+	ANSI bool
+
+	Messages map[uint32]string
+}
+
+func (t *MessageTable) name() ResourceName {
+	if t.Name == (ResourceName{}) {
+		return ResourceName{ID: 1}
+	}
+	return t.Name
+}
+
+// messageBlock is a run of consecutive message IDs.
+type messageBlock struct {
+	low, high uint32
+}
+
+// blocks returns the runs of consecutive message IDs, in ascending order.
+func (t *MessageTable) blocks() []messageBlock {
+	ids := []uint32{}
+	for id := range t.Messages {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	blocks := []messageBlock{}
+	for _, id := range ids {
+		if n := len(blocks); n > 0 && blocks[n-1].high+1 == id {
+			blocks[n-1].high = id
+			continue
+		}
+		blocks = append(blocks, messageBlock{id, id})
+	}
+	return blocks
+}
+
+// Encode encodes the message table: the number of blocks, a low ID, high ID
+// and offset for each block, then each block's entries. Each entry is a
+// length, flags and a null-terminated string, padded to 32 bits.
+func (t *MessageTable) Encode() ([]byte, error) {
+	if len(t.Messages) == 0 {
+		return nil, errors.New("message table has no messages")
+	}
+	le := binary.LittleEndian
+	blocks := t.blocks()
+	buf := le.AppendUint32(nil, uint32(len(blocks)))
+	entries := []byte{}
+	offset := 4 + 12*len(blocks)
+	for _, block := range blocks {
+		buf = le.AppendUint32(buf, block.low)
+		buf = le.AppendUint32(buf, block.high)
+		buf = le.AppendUint32(buf, uint32(offset+len(entries)))
+		for id := block.low; ; id++ {
+			flags, text := uint16(MessageEntryUnicode), utf16z(t.Messages[id])
+			if t.ANSI {
+				var err error
+				if text, err = ansiz(t.Messages[id], t.codepage()); err != nil {
+					return nil, fmt.Errorf("message %#x: %w", id, err)
+				}
+				flags = MessageEntryANSI
+			}
+			entry := pad32(append(make([]byte, 4), text...))
+			if len(entry) > 0xffff {
+				return nil, fmt.Errorf("message %#x is too long (%d bytes)", id, len(entry))
+			}
+			le.PutUint16(entry[0:], uint16(len(entry)))
+			le.PutUint16(entry[2:], flags)
+			entries = append(entries, entry...)
+			if id == block.high {
+				break
+			}
+		}
+	}
+	return append(buf, entries...), nil
+}
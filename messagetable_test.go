@@ -0,0 +1,52 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestMessageTableEncode(t *testing.T) {
+	for _, test := range []struct {
+		ansi bool
+		want string
+	}{
+		{false, `
+			02 00 00 00
+			01 00 00 00  02 00 00 00  1c 00 00 00
+			10 00 00 00  10 00 00 00  30 00 00 00
+			08 00 01 00  41 00 00 00
+			0c 00 01 00  42 00 43 00 00 00  00 00
+			08 00 01 00  44 00 00 00
+		`},
+		{true, `
+			02 00 00 00
+			01 00 00 00  02 00 00 00  1c 00 00 00
+			10 00 00 00  10 00 00 00  2c 00 00 00
+			08 00 00 00  41 00  00 00
+			08 00 00 00  42 43 00  00
+			08 00 00 00  44 00  00 00
+		`},
+	} {
+		table := &MessageTable{ANSI: test.ansi, Messages: map[uint32]string{0x10: "D", 2: "BC", 1: "A"}}
+		got, err := table.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := unhex(t, test.want); !bytes.Equal(got, want) {
+			t.Errorf("Encode(), ANSI %v =\n%s\nwant\n%s", test.ansi, hex.Dump(got), hex.Dump(want))
+		}
+	}
+	if _, err := (&MessageTable{}).Encode(); err == nil {
+		t.Error("Encode() of an empty table succeeded")
+	}
+}
@@ -17,6 +17,13 @@ const (
 	SizeOfNEResource            = 12
 )
 
+// Enumeration of NE module flags (incomplete)
+const (
+	NEFlagSingleData   = 0x0001
+	NEFlagMultipleData = 0x0002
+	NEFlagLibrary      = 0x8000
+)
+
 type NEFileHeader struct {
 	Signature                    [2]byte
 	MajorLinkerVersion           byte
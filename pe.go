@@ -77,6 +77,15 @@ const (
 
 	// SizeOfImageSectionHeader is the size of ImageSectionHeader.
 	SizeOfImageSectionHeader = 40
+
+	// SizeOfImageImportDescriptor is the size of ImageImportDescriptor.
+	SizeOfImageImportDescriptor = 20
+
+	// SizeOfImageExportDirectory is the size of ImageExportDirectory.
+	SizeOfImageExportDirectory = 40
+
+	// SizeOfImageBaseRelocation is the size of ImageBaseRelocation.
+	SizeOfImageBaseRelocation = 8
 )
 
 // Enumeration of useful field offsets.
@@ -220,6 +229,13 @@ const (
 	ImageDirectoryEntryCOMDescriptor = 14
 )
 
+// Enumeration of ordinal flags. Import lookup table entries with the flag
+// set import by ordinal, in the low 16 bits, rather than by name.
+const (
+	ImageOrdinalFlag32 = 0x80000000
+	ImageOrdinalFlag64 = 0x8000000000000000
+)
+
 // Enumeration of image section characteristics.
 const (
 	ImageSectionCharacteristicsNoPad                     = 0x00000008
@@ -282,10 +298,29 @@ const (
 	ImageRelBasedDir64            = 10
 )
 
-// Enumeration of resource types (incomplete)
+// Enumeration of resource types
 const (
-	ResourceIcon      = 3
-	ResourceGroupIcon = 14
+	ResourceCursor         = 1
+	ResourceBitmap         = 2
+	ResourceIcon           = 3
+	ResourceMenu           = 4
+	ResourceDialog         = 5
+	ResourceString         = 6
+	ResourceFontDir        = 7
+	ResourceFont           = 8
+	ResourceAccelerator    = 9
+	ResourceRCData         = 10
+	ResourceMessageTable   = 11
+	ResourceGroupCursor    = 12
+	ResourceGroupIcon      = 14
+	ResourceVersion        = 16
+	ResourceDlgInclude     = 17
+	ResourcePlugPlay       = 19
+	ResourceVXD            = 20
+	ResourceAnimatedCursor = 21
+	ResourceAnimatedIcon   = 22
+	ResourceHTML           = 23
+	ResourceManifest       = 24
 )
 
 // ImageDOSHeader is the structure of the DOS MZ Executable format. All PE
@@ -0,0 +1,393 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Layout of PE images.
+const (
+	PESectionAlignment = 0x1000
+	PEFileAlignment    = 0x200
+)
+
+// Conventional image bases, which linkers use by default.
+const (
+	PE32EXEImageBase     = 0x400000
+	PE32DLLImageBase     = 0x10000000
+	PE32PlusEXEImageBase = 0x140000000
+	PE32PlusDLLImageBase = 0x180000000
+)
+
+// peImageBase returns the conventional image base of an executable or a
+// DLL. In PE32+ executables, it is above 4 GiB, so that pointers truncated
+// to 32 bits are caught.
+func peImageBase(exeFormat EXEFormat, library bool) uint64 {
+	switch {
+	case exeFormat == PE32Plus && library:
+		return PE32PlusDLLImageBase
+	case exeFormat == PE32Plus:
+		return PE32PlusEXEImageBase
+	case library:
+		return PE32DLLImageBase
+	}
+	return PE32EXEImageBase
+}
+
+// peSection is a section of a PE image. Its size is known before the image
+// is laid out, but its data may depend on the addresses of other sections,
+// so it is only produced afterwards.
+type peSection struct {
+	name            string
+	characteristics uint32
+	size            int
+	data            func() []byte
+
+	// relocs are the offsets of absolute addresses in the section.
+	relocs []int
+
+	// rva and offset are assigned by layout.
+	rva    uint32
+	offset uint32
+}
+
+// peImage is a PE image: its sections, and the header fields that depend on
+// them.
+type peImage struct {
+	exeFormat          EXEFormat
+	imageBase          uint64
+	library            bool
+	relocatable        bool
+	dllCharacteristics uint16
+	sections           []*peSection
+	entryPoint         uint32
+	directories        [NumDirectoryEntries]ImageDataDirectory
+}
+
+// newPEImage lays out a PE image holding the code, exports, imports and
+// TLS of res, if any, and the resource tree, unless it is empty. DLLs and
+// images with a dynamic base are relocatable, and have their base
+// relocations in a .reloc section.
+func newPEImage(exeFormat EXEFormat, res *Resources, tree *ResourceTree) (*peImage, error) {
+	img := &peImage{
+		exeFormat:   exeFormat,
+		imageBase:   peImageBase(exeFormat, res.Library),
+		library:     res.Library,
+		relocatable: res.Library || res.DynamicBase,
+	}
+	if res.DynamicBase {
+		img.dllCharacteristics |= ImageDLLCharacteristicsDynamicBase
+	}
+	if res.HighEntropyVA {
+		if exeFormat != PE32Plus {
+			return nil, errors.New("high entropy VA is only supported in PE32+ executables")
+		}
+		if !res.DynamicBase {
+			return nil, errors.New("high entropy VA requires a dynamic base")
+		}
+		img.dllCharacteristics |= ImageDLLCharacteristicsHighEntropyVA
+	}
+	if res.NXCompat {
+		img.dllCharacteristics |= ImageDLLCharacteristicsNXCompat
+	}
+
+	var text *peSection
+	code := textSection(res, exeFormat, img.imageBase, 0)
+	if len(code.code) != 0 {
+		text = img.addSection(".text", ImageSectionCharacteristicsContainsCode|ImageSectionCharacteristicsMemoryExecute|ImageSectionCharacteristicsMemoryRead, len(code.code), func() []byte {
+			return textSection(res, exeFormat, img.imageBase, text.rva).code
+		})
+		text.relocs = code.relocs
+	}
+
+	var edata *peSection
+	if len(res.Exports) != 0 {
+		data, err := encodeExports(res.ModuleName, res.Exports, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		edata = img.addSection(".edata", ImageSectionCharacteristicsContainsInitializedData|ImageSectionCharacteristicsMemoryRead, len(data), func() []byte {
+			stubRVA := uint32(0)
+			if code.stub >= 0 {
+				stubRVA = text.rva + uint32(code.stub)
+			}
+			data, err := encodeExports(res.ModuleName, res.Exports, edata.rva, stubRVA)
+			must(err, "encoding exports")
+			return data
+		})
+	}
+
+	var idata *peSection
+	var iatSize int
+	if len(res.Imports) != 0 {
+		if err := checkImports(res.Imports); err != nil {
+			return nil, err
+		}
+		var data []byte
+		var err error
+		if data, iatSize, err = encodeImports(res.Imports, exeFormat, 0); err != nil {
+			return nil, err
+		}
+		idata = img.addSection(".idata", ImageSectionCharacteristicsContainsInitializedData|ImageSectionCharacteristicsMemoryRead|ImageSectionCharacteristicsMemoryWrite, len(data), func() []byte {
+			data, _, err := encodeImports(res.Imports, exeFormat, idata.rva)
+			must(err, "encoding imports")
+			return data
+		})
+	}
+
+	var tls *peSection
+	var tlsDirectory int
+	if res.TLS != nil {
+		callbacks := func() []int {
+			rvas := []int{}
+			for _, offset := range code.callbacks {
+				rvas = append(rvas, int(text.rva)+offset)
+			}
+			return rvas
+		}
+		data, relocs, directory := encodeTLS(res.TLS, exeFormat, img.imageBase, 0, callbacks())
+		tls = img.addSection(".tls", ImageSectionCharacteristicsContainsInitializedData|ImageSectionCharacteristicsMemoryRead|ImageSectionCharacteristicsMemoryWrite, len(data), func() []byte {
+			data, _, _ := encodeTLS(res.TLS, exeFormat, img.imageBase, tls.rva, callbacks())
+			return data
+		})
+		tls.relocs, tlsDirectory = relocs, directory
+	}
+
+	var rsrc *peSection
+	if len(tree.types) != 0 {
+		rsrc = img.addSection(".rsrc", ImageSectionCharacteristicsContainsInitializedData|ImageSectionCharacteristicsMemoryRead|ImageSectionCharacteristicsMemoryWrite, tree.PESize(), func() []byte {
+			buf := bytes.Buffer{}
+			tree.WritePE(&buf, rsrc.rva)
+			return buf.Bytes()
+		})
+	}
+	if len(img.sections) == 0 {
+		return nil, errors.New("executable has no resources or code")
+	}
+
+	var reloc *peSection
+	if img.relocatable {
+		sections := img.sections
+		reloc = img.addSection(".reloc", ImageSectionCharacteristicsContainsInitializedData|ImageSectionCharacteristicsMemoryDiscardable|ImageSectionCharacteristicsMemoryRead, len(encodeRelocs(sections, exeFormat)), func() []byte {
+			return encodeRelocs(sections, exeFormat)
+		})
+	}
+
+	img.layout()
+	if code.entryPoint >= 0 {
+		img.entryPoint = text.rva + uint32(code.entryPoint)
+	}
+	if edata != nil {
+		img.directories[ImageDirectoryEntryExport] = ImageDataDirectory{
+			VirtualAddress: edata.rva,
+			Size:           uint32(edata.size),
+		}
+	}
+	if idata != nil {
+		img.directories[ImageDirectoryEntryImport] = ImageDataDirectory{
+			VirtualAddress: idata.rva + uint32(iatSize),
+			Size:           uint32((len(res.Imports) + 1) * SizeOfImageImportDescriptor),
+		}
+		img.directories[ImageDirectoryEntryIAT] = ImageDataDirectory{
+			VirtualAddress: idata.rva,
+			Size:           uint32(iatSize),
+		}
+	}
+	if tls != nil {
+		img.directories[ImageDirectoryEntryTLS] = ImageDataDirectory{
+			VirtualAddress: tls.rva + uint32(tlsDirectory),
+			Size:           uint32(tls.size - tlsDirectory),
+		}
+	}
+	if rsrc != nil {
+		img.directories[ImageDirectoryEntryResource] = ImageDataDirectory{
+			VirtualAddress: rsrc.rva,
+			Size:           uint32(rsrc.size),
+		}
+	}
+	if reloc != nil {
+		img.directories[ImageDirectoryEntryBaseReloc] = ImageDataDirectory{
+			VirtualAddress: reloc.rva,
+			Size:           uint32(reloc.size),
+		}
+	}
+	return img, nil
+}
+
+func (img *peImage) addSection(name string, characteristics uint32, size int, data func() []byte) *peSection {
+	s := &peSection{name: name, characteristics: characteristics, size: size, data: data}
+	img.sections = append(img.sections, s)
+	return s
+}
+
+func (img *peImage) ntHeadersSize() int {
+	if img.exeFormat == PE32Plus {
+		return SizeOfImageNTHeadersPE32Plus
+	}
+	return SizeOfImageNTHeadersPE32
+}
+
+func (img *peImage) headersSize() int {
+	size := SizeOfImageDOSHeader + img.ntHeadersSize() + len(img.sections)*SizeOfImageSectionHeader
+	return align(size, PEFileAlignment)
+}
+
+// layout assigns addresses and file offsets to the sections, in order.
+func (img *peImage) layout() {
+	rva := PESectionAlignment
+	offset := img.headersSize()
+	for _, s := range img.sections {
+		s.rva, s.offset = uint32(rva), uint32(offset)
+		rva += align(s.size, PESectionAlignment)
+		offset += align(s.size, PEFileAlignment)
+	}
+}
+
+func (img *peImage) imageSize() uint32 {
+	last := img.sections[len(img.sections)-1]
+	return last.rva + uint32(align(last.size, PESectionAlignment))
+}
+
+// sizes returns the total size of the code and initialized data sections,
+// and the address of the first of each.
+func (img *peImage) sizes() (codeSize, dataSize, codeBase, dataBase uint32) {
+	for _, s := range img.sections {
+		size := uint32(align(s.size, PEFileAlignment))
+		switch {
+		case s.characteristics&ImageSectionCharacteristicsContainsCode != 0:
+			if codeBase == 0 {
+				codeBase = s.rva
+			}
+			codeSize += size
+		case s.characteristics&ImageSectionCharacteristicsContainsInitializedData != 0:
+			if dataBase == 0 {
+				dataBase = s.rva
+			}
+			dataSize += size
+		}
+	}
+	return
+}
+
+// fileCharacteristics returns the characteristics of the file header.
+func (img *peImage) fileCharacteristics() uint16 {
+	characteristics := uint16(ImageFileExecutableImage)
+	if !img.relocatable {
+		characteristics |= ImageFileRelocsStripped
+	}
+	if img.library {
+		characteristics |= ImageFileDLL
+	}
+	if img.exeFormat == PE32Plus {
+		characteristics |= ImageFileLargeAddressAware
+	} else {
+		characteristics |= ImageFile32BitMachine
+	}
+	return characteristics
+}
+
+// write writes the image after the DOS header.
+func (img *peImage) write(w io.Writer) {
+	codeSize, dataSize, codeBase, dataBase := img.sizes()
+	fileHeader := ImageFileHeader{
+		NumberOfSections: uint16(len(img.sections)),
+		Characteristics:  img.fileCharacteristics(),
+	}
+	if img.exeFormat == PE32Plus {
+		fileHeader.Machine = ImageFileMachineAMD64
+		fileHeader.SizeOfOptionalHeader = SizeOfImageOptionalHeaderPE32Plus
+		must(binary.Write(w, binary.LittleEndian, ImageNTHeadersPE32Plus{
+			Signature:  PESignature,
+			FileHeader: fileHeader,
+			OptionalHeader: ImageOptionalHeaderPE32Plus{
+				Magic:                       ImageNTOptionalHeaderPE32PlusMagic,
+				SizeOfCode:                  codeSize,
+				SizeOfInitializedData:       dataSize,
+				AddressOfEntryPoint:         img.entryPoint,
+				BaseOfCode:                  codeBase,
+				ImageBase:                   img.imageBase,
+				SectionAlignment:            PESectionAlignment,
+				FileAlignment:               PEFileAlignment,
+				MajorOperatingSystemVersion: 5,
+				MinorOperatingSystemVersion: 2,
+				MajorSubsystemVersion:       5,
+				MinorSubsystemVersion:       2,
+				SizeOfImage:                 img.imageSize(),
+				SizeOfHeaders:               uint32(img.headersSize()),
+				Subsystem:                   ImageSubsystemWindowsGUI,
+				DllCharacteristics:          img.dllCharacteristics,
+				SizeOfStackReserve:          0x100000,
+				SizeOfStackCommit:           0x1000,
+				SizeOfHeapReserve:           0x100000,
+				SizeOfHeapCommit:            0x1000,
+				NumberOfRvaAndSizes:         NumDirectoryEntries,
+				DataDirectory:               img.directories,
+			},
+		}), "writing PE32+ header")
+	} else {
+		fileHeader.Machine = ImageFileMachinei386
+		fileHeader.SizeOfOptionalHeader = SizeOfImageOptionalHeaderPE32
+		must(binary.Write(w, binary.LittleEndian, ImageNTHeadersPE32{
+			Signature:  PESignature,
+			FileHeader: fileHeader,
+			OptionalHeader: ImageOptionalHeaderPE32{
+				Magic:                       ImageNTOptionalHeaderPE32Magic,
+				SizeOfCode:                  codeSize,
+				SizeOfInitializedData:       dataSize,
+				AddressOfEntryPoint:         img.entryPoint,
+				BaseOfCode:                  codeBase,
+				BaseOfData:                  dataBase,
+				ImageBase:                   uint32(img.imageBase),
+				SectionAlignment:            PESectionAlignment,
+				FileAlignment:               PEFileAlignment,
+				MajorOperatingSystemVersion: 4,
+				MajorSubsystemVersion:       4,
+				SizeOfImage:                 img.imageSize(),
+				SizeOfHeaders:               uint32(img.headersSize()),
+				Subsystem:                   ImageSubsystemWindowsGUI,
+				DllCharacteristics:          img.dllCharacteristics,
+				SizeOfStackReserve:          0x100000,
+				SizeOfStackCommit:           0x1000,
+				SizeOfHeapReserve:           0x100000,
+				SizeOfHeapCommit:            0x1000,
+				NumberOfRvaAndSizes:         NumDirectoryEntries,
+				DataDirectory:               img.directories,
+			},
+		}), "writing PE32 header")
+	}
+
+	for _, s := range img.sections {
+		header := ImageSectionHeader{
+			PhysicalAddressOrVirtualSize: uint32(s.size),
+			VirtualAddress:               s.rva,
+			SizeOfRawData:                uint32(align(s.size, PEFileAlignment)),
+			PointerToRawData:             s.offset,
+			Characteristics:              s.characteristics,
+		}
+		copy(header.Name[:], s.name)
+		must(binary.Write(w, binary.LittleEndian, header), "writing section header")
+	}
+
+	offset := SizeOfImageDOSHeader + img.ntHeadersSize() + len(img.sections)*SizeOfImageSectionHeader
+	for _, s := range img.sections {
+		_, err := w.Write(make([]byte, int(s.offset)-offset))
+		must(err, "writing padding to section %s", s.name)
+		data := s.data()
+		_, err = w.Write(data)
+		must(err, "writing section %s", s.name)
+		offset = int(s.offset) + len(data)
+	}
+	_, err := w.Write(make([]byte, align(offset, PEFileAlignment)-offset))
+	must(err, "writing padding to end of file")
+}
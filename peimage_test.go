@@ -0,0 +1,387 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// parsePE generates the executable for res and parses it with debug/pe.
+func parsePE(t *testing.T, res *Resources, exeFormat EXEFormat) *pe.File {
+	t.Helper()
+	buf := bytes.Buffer{}
+	if err := png2exe(&buf, res, exeFormat); err != nil {
+		t.Fatal(err)
+	}
+	f, err := pe.NewFile(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// imageBase returns the image base and the data directories of f.
+func imageBase(f *pe.File) (uint64, [16]pe.DataDirectory) {
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		return uint64(h.ImageBase), h.DataDirectory
+	case *pe.OptionalHeader64:
+		return h.ImageBase, h.DataDirectory
+	}
+	panic("missing optional header")
+}
+
+// readRVA returns the n bytes of f at rva.
+func readRVA(t *testing.T, f *pe.File, rva uint32, n int) []byte {
+	t.Helper()
+	for _, s := range f.Sections {
+		if rva >= s.VirtualAddress && rva+uint32(n) <= s.VirtualAddress+s.VirtualSize {
+			data, err := s.Data()
+			if err != nil {
+				t.Fatal(err)
+			}
+			offset := rva - s.VirtualAddress
+			return data[offset : offset+uint32(n)]
+		}
+	}
+	t.Fatalf("no section holds %d bytes at %#x", n, rva)
+	return nil
+}
+
+func TestPEProgram(t *testing.T) {
+	for _, exeFormat := range []EXEFormat{PE32, PE32Plus} {
+		f := parsePE(t, &Resources{Program: &Program{ExitCode: 3}}, exeFormat)
+		entryPoint := uint32(0)
+		switch h := f.OptionalHeader.(type) {
+		case *pe.OptionalHeader32:
+			entryPoint = h.AddressOfEntryPoint
+		case *pe.OptionalHeader64:
+			entryPoint = h.AddressOfEntryPoint
+		}
+		text := f.Section(".text")
+		if text == nil {
+			t.Fatalf("%s: no .text section", exeFormat)
+		}
+		if f.Section(".rsrc") != nil {
+			t.Errorf("%s: unexpected .rsrc section", exeFormat)
+		}
+		if text.Characteristics&ImageSectionCharacteristicsMemoryExecute == 0 {
+			t.Errorf("%s: .text is not executable", exeFormat)
+		}
+		if entryPoint < text.VirtualAddress || entryPoint >= text.VirtualAddress+text.VirtualSize {
+			t.Errorf("%s: entry point %#x is outside of .text", exeFormat, entryPoint)
+		}
+	}
+}
+
+func TestPEEmpty(t *testing.T) {
+	for _, res := range []*Resources{
+		{},
+		{Library: true},
+		{Strings: []*StringTable{{ResourceLanguage: ResourceLanguage{Language: DefaultLanguage}}}},
+	} {
+		if err := png2exe(&bytes.Buffer{}, res, PE32); err == nil {
+			t.Errorf("png2exe of %+v succeeded", res)
+		}
+	}
+}
+
+// readString returns the null-terminated string of f at rva.
+func readString(t *testing.T, f *pe.File, rva uint32) string {
+	t.Helper()
+	s := []byte{}
+	for {
+		c := readRVA(t, f, rva+uint32(len(s)), 1)[0]
+		if c == 0 {
+			return string(s)
+		}
+		s = append(s, c)
+	}
+}
+
+func testCodeResources() *Resources {
+	return &Resources{
+		ModuleName: "MOCK.DLL",
+		Library:    true,
+		Program:    &Program{},
+		Imports: []Import{{
+			DLL:       "KERNEL32.dll",
+			Functions: []ImportFunction{{Name: "ExitProcess"}, {Name: "GetTickCount"}},
+		}},
+		Exports: []Export{{Name: "Foo"}, {Ordinal: 5}, {Name: "Bar", Forwarder: "NTDLL.RtlBar"}},
+		TLS:     &TLS{Data: []byte("mock"), ZeroFill: 12, Callbacks: 2},
+		Raw:     []*RawResource{NewRawResource(ResourceName{ID: ResourceRCData}, ResourceName{ID: 1}, []byte("mock"))},
+	}
+}
+
+func TestPEImports(t *testing.T) {
+	for _, exeFormat := range []EXEFormat{PE32, PE32Plus} {
+		f := parsePE(t, testCodeResources(), exeFormat)
+		got, err := f.ImportedSymbols()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"ExitProcess:KERNEL32.dll", "GetTickCount:KERNEL32.dll"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: imported symbols %q, want %q", exeFormat, got, want)
+		}
+	}
+}
+
+func TestPEExports(t *testing.T) {
+	for _, exeFormat := range []EXEFormat{PE32, PE32Plus} {
+		f := parsePE(t, testCodeResources(), exeFormat)
+		_, dirs := imageBase(f)
+		dir := dirs[ImageDirectoryEntryExport]
+		export := ImageExportDirectory{}
+		if err := binary.Read(bytes.NewReader(readRVA(t, f, dir.VirtualAddress, SizeOfImageExportDirectory)), binary.LittleEndian, &export); err != nil {
+			t.Fatal(err)
+		}
+		if name := readString(t, f, export.Name); name != "MOCK.DLL" {
+			t.Errorf("%s: DLL name %q", exeFormat, name)
+		}
+		if export.Base != 1 || export.NumberOfFunctions != 5 || export.NumberOfNames != 2 {
+			t.Fatalf("%s: export directory %+v", exeFormat, export)
+		}
+		functions := readRVA(t, f, export.AddressOfFunctions, 4*int(export.NumberOfFunctions))
+		function := func(ordinal uint32) uint32 {
+			return binary.LittleEndian.Uint32(functions[4*(ordinal-export.Base):])
+		}
+		names := map[string]uint32{}
+		for i := 0; i < int(export.NumberOfNames); i++ {
+			name := readString(t, f, binary.LittleEndian.Uint32(readRVA(t, f, export.AddressOfNames+uint32(4*i), 4)))
+			index := binary.LittleEndian.Uint16(readRVA(t, f, export.AddressOfNameOrdinals+uint32(2*i), 2))
+			names[name] = uint32(index) + export.Base
+		}
+		if want := map[string]uint32{"Bar": 2, "Foo": 1}; !reflect.DeepEqual(names, want) {
+			t.Errorf("%s: named exports %v, want %v", exeFormat, names, want)
+		}
+
+		stub := function(1)
+		if !bytes.Equal(readRVA(t, f, stub, len(exportStub)), exportStub) {
+			t.Errorf("%s: export 1 does not point to the stub", exeFormat)
+		}
+		if function(5) != stub {
+			t.Errorf("%s: export 5 does not point to the stub", exeFormat)
+		}
+		if function(3) != 0 || function(4) != 0 {
+			t.Errorf("%s: unused ordinals are not zero", exeFormat)
+		}
+		forwarder := function(2)
+		if forwarder < dir.VirtualAddress || forwarder >= dir.VirtualAddress+dir.Size {
+			t.Errorf("%s: forwarder %#x is outside of the export directory", exeFormat, forwarder)
+		} else if s := readString(t, f, forwarder); s != "NTDLL.RtlBar" {
+			t.Errorf("%s: forwarder %q", exeFormat, s)
+		}
+	}
+}
+
+// readPointer returns the absolute address of f at rva.
+func readPointer(t *testing.T, f *pe.File, rva uint32) uint64 {
+	t.Helper()
+	if f.Machine == pe.IMAGE_FILE_MACHINE_AMD64 {
+		return binary.LittleEndian.Uint64(readRVA(t, f, rva, 8))
+	}
+	return uint64(binary.LittleEndian.Uint32(readRVA(t, f, rva, 4)))
+}
+
+// baseRelocs returns the addresses that the base relocations of f fix up.
+func baseRelocs(t *testing.T, f *pe.File) []uint32 {
+	t.Helper()
+	_, dirs := imageBase(f)
+	dir := dirs[ImageDirectoryEntryBaseReloc]
+	data := readRVA(t, f, dir.VirtualAddress, int(dir.Size))
+	rvas := []uint32{}
+	for len(data) != 0 {
+		page := binary.LittleEndian.Uint32(data)
+		size := binary.LittleEndian.Uint32(data[4:])
+		if size < SizeOfImageBaseRelocation || size%4 != 0 || int(size) > len(data) {
+			t.Fatalf("invalid base relocation block size %d", size)
+		}
+		for i := SizeOfImageBaseRelocation; i < int(size); i += 2 {
+			entry := binary.LittleEndian.Uint16(data[i:])
+			switch entry >> 12 {
+			case ImageRelBasedAbsolute:
+			case ImageRelBasedHighLow, ImageRelBasedDir64:
+				rvas = append(rvas, page+uint32(entry&0xfff))
+			default:
+				t.Fatalf("unexpected base relocation type %d", entry>>12)
+			}
+		}
+		data = data[size:]
+	}
+	return rvas
+}
+
+func TestPEImageBase(t *testing.T) {
+	for _, test := range []struct {
+		exeFormat EXEFormat
+		library   bool
+		want      uint64
+	}{
+		{PE32, false, PE32EXEImageBase},
+		{PE32, true, PE32DLLImageBase},
+		{PE32Plus, false, PE32PlusEXEImageBase},
+		{PE32Plus, true, PE32PlusDLLImageBase},
+	} {
+		f := parsePE(t, &Resources{Library: test.library, Program: &Program{}}, test.exeFormat)
+		if base, _ := imageBase(f); base != test.want {
+			t.Errorf("%s, library %v: image base %#x, want %#x", test.exeFormat, test.library, base, test.want)
+		}
+		if dll := f.Characteristics&ImageFileDLL != 0; dll != test.library {
+			t.Errorf("%s, library %v: IMAGE_FILE_DLL is %v", test.exeFormat, test.library, dll)
+		}
+		if stripped := f.Characteristics&ImageFileRelocsStripped != 0; stripped == test.library {
+			t.Errorf("%s, library %v: IMAGE_FILE_RELOCS_STRIPPED is %v", test.exeFormat, test.library, stripped)
+		}
+	}
+}
+
+func TestPERelocs(t *testing.T) {
+	for _, exeFormat := range []EXEFormat{PE32, PE32Plus} {
+		f := parsePE(t, &Resources{DynamicBase: true, Program: &Program{ExitCode: 3}}, exeFormat)
+		base, _ := imageBase(f)
+		relocs := baseRelocs(t, f)
+		if len(relocs) != 1 {
+			t.Fatalf("%s: %d base relocations, want 1", exeFormat, len(relocs))
+		}
+		address := readPointer(t, f, relocs[0])
+		if result := binary.LittleEndian.Uint32(readRVA(t, f, uint32(address-base), 4)); result != 3 {
+			t.Errorf("%s: entry point returns %d, want 3", exeFormat, result)
+		}
+	}
+}
+
+func TestPEEmptyRelocs(t *testing.T) {
+	res := &Resources{
+		Library: true,
+		Raw:     []*RawResource{NewRawResource(ResourceName{ID: ResourceRCData}, ResourceName{ID: 1}, []byte("mock"))},
+	}
+	f := parsePE(t, res, PE32)
+	if f.Section(".reloc") == nil {
+		t.Fatal("relocatable image without fixups has no .reloc section")
+	}
+	if relocs := baseRelocs(t, f); len(relocs) != 0 {
+		t.Errorf("unexpected base relocations %#x", relocs)
+	}
+}
+
+func TestPEHighEntropyVA(t *testing.T) {
+	f := parsePE(t, &Resources{Program: &Program{}, DynamicBase: true, HighEntropyVA: true}, PE32Plus)
+	want := uint16(ImageDLLCharacteristicsDynamicBase | ImageDLLCharacteristicsHighEntropyVA)
+	if got := f.OptionalHeader.(*pe.OptionalHeader64).DllCharacteristics; got != want {
+		t.Errorf("DLL characteristics %#04x, want %#04x", got, want)
+	}
+	for _, test := range []struct {
+		exeFormat   EXEFormat
+		dynamicBase bool
+	}{{PE32, true}, {PE32Plus, false}} {
+		res := &Resources{Program: &Program{}, DynamicBase: test.dynamicBase, HighEntropyVA: true}
+		if err := png2exe(&bytes.Buffer{}, res, test.exeFormat); err == nil {
+			t.Errorf("%s, dynamic base %v: high entropy VA was accepted", test.exeFormat, test.dynamicBase)
+		}
+	}
+}
+
+func TestPETLS(t *testing.T) {
+	for _, exeFormat := range []EXEFormat{PE32, PE32Plus} {
+		res := testCodeResources()
+		f := parsePE(t, res, exeFormat)
+		base, dirs := imageBase(f)
+		dir := dirs[ImageDirectoryEntryTLS]
+		tls := ImageTLSDirectoryPE32Plus{}
+		var err error
+		if exeFormat == PE32Plus {
+			err = binary.Read(bytes.NewReader(readRVA(t, f, dir.VirtualAddress, int(dir.Size))), binary.LittleEndian, &tls)
+		} else {
+			tls32 := ImageTLSDirectoryPE32{}
+			err = binary.Read(bytes.NewReader(readRVA(t, f, dir.VirtualAddress, int(dir.Size))), binary.LittleEndian, &tls32)
+			tls = tls32.To64()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data := readRVA(t, f, uint32(tls.StartAddressOfRawData-base), int(tls.EndAddressOfRawData-tls.StartAddressOfRawData))
+		if !bytes.Equal(data, res.TLS.Data) {
+			t.Errorf("%s: TLS data %q, want %q", exeFormat, data, res.TLS.Data)
+		}
+		if tls.SizeOfZeroFill != res.TLS.ZeroFill {
+			t.Errorf("%s: zero fill %d, want %d", exeFormat, tls.SizeOfZeroFill, res.TLS.ZeroFill)
+		}
+
+		pointerSize := uint32(4)
+		if exeFormat == PE32Plus {
+			pointerSize = 8
+		}
+		// Every absolute address needs a base relocation.
+		pointers := []uint32{dir.VirtualAddress}
+		for i := uint32(1); i < 4; i++ {
+			pointers = append(pointers, dir.VirtualAddress+i*pointerSize)
+		}
+		text := f.Section(".text")
+		callbacks := uint32(tls.AddressOfCallBacks - base)
+		for i := 0; ; i++ {
+			rva := callbacks + uint32(i)*pointerSize
+			callback := readPointer(t, f, rva)
+			if callback == 0 {
+				if i != res.TLS.Callbacks {
+					t.Errorf("%s: %d callbacks, want %d", exeFormat, i, res.TLS.Callbacks)
+				}
+				break
+			}
+			pointers = append(pointers, rva)
+			if callback < base+uint64(text.VirtualAddress) || callback >= base+uint64(text.VirtualAddress+text.VirtualSize) {
+				t.Errorf("%s: callback %d at %#x is outside of .text", exeFormat, i+1, callback)
+			}
+		}
+		relocs := map[uint32]bool{}
+		for _, rva := range baseRelocs(t, f) {
+			relocs[rva] = true
+		}
+		for _, rva := range pointers {
+			if !relocs[rva] {
+				t.Errorf("%s: address at %#x has no base relocation", exeFormat, rva)
+			}
+		}
+	}
+}
+
+func TestPESections(t *testing.T) {
+	const (
+		code = ImageSectionCharacteristicsContainsCode | ImageSectionCharacteristicsMemoryExecute | ImageSectionCharacteristicsMemoryRead
+		data = ImageSectionCharacteristicsContainsInitializedData | ImageSectionCharacteristicsMemoryRead
+	)
+	want := map[string]uint32{
+		".text":  code,
+		".edata": data,
+		".idata": data | ImageSectionCharacteristicsMemoryWrite,
+		".tls":   data | ImageSectionCharacteristicsMemoryWrite,
+		".rsrc":  data | ImageSectionCharacteristicsMemoryWrite,
+		".reloc": data | ImageSectionCharacteristicsMemoryDiscardable,
+	}
+	for _, exeFormat := range []EXEFormat{PE32, PE32Plus} {
+		f := parsePE(t, testCodeResources(), exeFormat)
+		got := map[string]uint32{}
+		for _, s := range f.Sections {
+			got[s.Name] = s.Characteristics
+			if s.VirtualAddress%PESectionAlignment != 0 || s.Offset%PEFileAlignment != 0 {
+				t.Errorf("%s: section %s is misaligned", exeFormat, s.Name)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: sections %#x, want %#x", exeFormat, got, want)
+		}
+	}
+}
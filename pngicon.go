@@ -0,0 +1,100 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+)
+
+// PNGSignature is the signature at the start of every PNG stream. Icon
+// readers use it to tell PNG icon images apart from DIBs, which start with
+// the size of their BitmapInfoHeaderV3.
+var PNGSignature = [8]byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+// PNGIconImage is an icon image stored as a raw PNG stream. Windows Vista
+// and later accept these in .ico files and RT_ICON resources, and they are
+// normally used for 256x256 images.
+type PNGIconImage struct {
+	width, height int
+	bpp           int
+	data          []byte
+}
+
+// NewPNGIconImage creates an icon image from a PNG stream. The stream is
+// stored unmodified. nbit is the bit depth recorded in icon directories; if
+// it is zero, 32 is used.
+func NewPNGIconImage(data []byte, nbit int) (*PNGIconImage, error) {
+	if !bytes.HasPrefix(data, PNGSignature[:]) {
+		return nil, errors.New("missing PNG signature")
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if nbit == 0 {
+		nbit = 32
+	}
+	return &PNGIconImage{
+		width:  config.Width,
+		height: config.Height,
+		bpp:    nbit,
+		data:   data,
+	}, nil
+}
+
+// EncodePNG encodes img as a PNG stream suitable for NewPNGIconImage.
+func EncodePNG(img image.Image) []byte {
+	buf := bytes.Buffer{}
+	must(png.Encode(&buf, img), "encoding png")
+	return buf.Bytes()
+}
+
+func (p *PNGIconImage) IconGroupWidth() int {
+	if p.width >= 256 {
+		return 0
+	}
+	return p.width
+}
+
+func (p *PNGIconImage) IconGroupHeight() int {
+	if p.height >= 256 {
+		return 0
+	}
+	return p.height
+}
+
+func (p *PNGIconImage) Width() int {
+	return p.width
+}
+
+func (p *PNGIconImage) Height() int {
+	return p.height
+}
+
+func (p *PNGIconImage) ColorCount() int {
+	return 0
+}
+
+func (p *PNGIconImage) BitCount() int {
+	return p.bpp
+}
+
+func (p *PNGIconImage) Size() int {
+	return len(p.data)
+}
+
+func (p *PNGIconImage) Write(w io.Writer) {
+	_, err := w.Write(p.data)
+	must(err, "writing png icon image")
+}
@@ -0,0 +1,101 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Program describes the code of a runnable PE executable. The code is
+// hand-assembled and does nothing but return from the entry point.
+type Program struct {
+	// ExitCode is returned by the entry point, and becomes the exit code of
+	// the process. It is ignored in DLLs, whose entry point returns TRUE.
+	ExitCode uint32
+}
+
+// exportStub is the function that exports point to: "xor eax, eax; ret".
+// It has the same encoding for i386 and AMD64.
+var exportStub = []byte{0x31, 0xc0, 0xc3}
+
+// text is the contents of a .text section.
+type text struct {
+	code []byte
+
+	// entryPoint and stub are the offsets of the entry point and of
+	// exportStub in code, or -1 if absent.
+	entryPoint, stub int
+
+	// callbacks are the offsets of the TLS callbacks in code.
+	callbacks []int
+
+	// relocs are the offsets of absolute addresses in code.
+	relocs []int
+}
+
+// textSection returns the contents of the .text section at rva of an
+// executable based at imageBase, with the code needed by res: the entry
+// point of its Program, exportStub if it has exports that are not
+// forwarded, and its TLS callbacks.
+//
+// The entry point is "mov eax, [result]; ret", followed by the 32-bit
+// result: ExitCode, or TRUE in DLLs, whose entry point is DllMain. Loading
+// the result from an absolute address gives the image a relocation. The
+// encoding is the same for i386 and AMD64, except that the address is
+// 64-bit on AMD64; there, writing to eax also clears the upper half of rax.
+// On i386, DllMain and TLS callbacks pop their three stdcall arguments with
+// "ret 12". Functions are aligned to 16 bytes.
+func textSection(res *Resources, exeFormat EXEFormat, imageBase uint64, rva uint32) text {
+	le := binary.LittleEndian
+	t := text{entryPoint: -1, stub: -1}
+	stdcallRet := []byte{0xc3} // ret
+	if exeFormat == PE32 {
+		stdcallRet = []byte{0xc2, 12, 0} // ret 12
+	}
+	function := func() int {
+		t.code = append(t.code, bytes.Repeat([]byte{0xcc}, align(len(t.code), 16)-len(t.code))...) // int3
+		return len(t.code)
+	}
+	if p := res.Program; p != nil {
+		result, ret := p.ExitCode, []byte{0xc3} // ret
+		if res.Library {
+			result, ret = 1, stdcallRet
+		}
+		t.entryPoint = function()
+		t.code = append(t.code, 0xa1) // mov eax, [moffs]
+		t.relocs = append(t.relocs, len(t.code))
+		if exeFormat == PE32Plus {
+			t.code = le.AppendUint64(t.code, 0)
+		} else {
+			t.code = le.AppendUint32(t.code, 0)
+		}
+		t.code = append(t.code, ret...)
+		t.code = pad32(t.code)
+		address := imageBase + uint64(rva) + uint64(len(t.code))
+		if exeFormat == PE32Plus {
+			le.PutUint64(t.code[t.relocs[0]:], address)
+		} else {
+			le.PutUint32(t.code[t.relocs[0]:], uint32(address))
+		}
+		t.code = le.AppendUint32(t.code, result)
+	}
+	if hasStub(res.Exports) {
+		t.stub = function()
+		t.code = append(t.code, exportStub...)
+	}
+	if res.TLS != nil {
+		for i := 0; i < res.TLS.Callbacks; i++ {
+			t.callbacks = append(t.callbacks, function())
+			t.code = append(t.code, stdcallRet...)
+		}
+	}
+	return t
+}
@@ -0,0 +1,63 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+// SizeOfRelocPage is the size of the pages that base relocation blocks
+// cover.
+const SizeOfRelocPage = 0x1000
+
+// encodeRelocs encodes the contents of a .reloc section holding the base
+// relocations of sections: a block for each page with absolute addresses,
+// each of which has an ImageRelBasedHighLow entry, or ImageRelBasedDir64 in
+// PE32+ executables. Blocks are padded to 32 bits with an
+// ImageRelBasedAbsolute entry. Without any absolute addresses, it is a
+// single empty block for the first section, as a relocatable image needs
+// base relocations. As sections are page-aligned, the size does not depend
+// on the layout.
+func encodeRelocs(sections []*peSection, exeFormat EXEFormat) []byte {
+	le := binary.LittleEndian
+	typ := uint16(ImageRelBasedHighLow)
+	if exeFormat == PE32Plus {
+		typ = ImageRelBasedDir64
+	}
+	buf := bytes.Buffer{}
+	for _, s := range sections {
+		relocs := append([]int{}, s.relocs...)
+		sort.Ints(relocs)
+		for len(relocs) != 0 {
+			page := relocs[0] &^ (SizeOfRelocPage - 1)
+			entries := []byte{}
+			for len(relocs) != 0 && relocs[0] < page+SizeOfRelocPage {
+				entries = le.AppendUint16(entries, typ<<12|uint16(relocs[0]-page))
+				relocs = relocs[1:]
+			}
+			entries = pad32(entries)
+			must(binary.Write(&buf, le, ImageBaseRelocation{
+				VirtualAddress: s.rva + uint32(page),
+				SizeOfBlock:    uint32(SizeOfImageBaseRelocation + len(entries)),
+			}), "writing base relocation block")
+			buf.Write(entries)
+		}
+	}
+	if buf.Len() == 0 {
+		block := ImageBaseRelocation{SizeOfBlock: SizeOfImageBaseRelocation}
+		if len(sections) != 0 {
+			block.VirtualAddress = sections[0].rva
+		}
+		must(binary.Write(&buf, le, block), "writing base relocation block")
+	}
+	return buf.Bytes()
+}
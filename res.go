@@ -9,6 +9,17 @@
 
 package main
 
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
 const (
 	SizeOfGroupIconDirectory      = 6
 	SizeOfGroupIconDirectoryEntry = 14
@@ -34,3 +45,612 @@ type GroupIconDirectoryEntry struct {
 	ImageSize  uint32
 	ResourceID uint16
 }
+
+// GroupCursorDirectoryEntry are entries of the GroupIconDirectory structure
+// when it is used for cursors, in which case Type is 2.
+type GroupCursorDirectoryEntry struct {
+	Width      uint16
+	Height     uint16 // twice the image height, as in the DIB header
+	NumPlanes  uint16
+	BPP        uint16
+	ImageSize  uint32 // including the CursorHotspot
+	ResourceID uint16
+}
+
+// CursorHotspot precedes the image data of ResourceCursor resources.
+type CursorHotspot struct {
+	X uint16
+	Y uint16
+}
+
+const SizeOfCursorHotspot = 4
+
+// DefaultLanguage is the language of resources that do not specify one.
+const DefaultLanguage = LangEnglishUS
+
+// DefaultCodepage is the ANSI codepage of strings that do not belong to a
+// resource, such as resource and module names.
+const DefaultCodepage = 1252
+
+// Resources is the set of resources to be stored in an executable.
+type Resources struct {
+	Icons   []*IconGroup
+	Cursors []*CursorGroup
+
+	// AnimatedCursors are stored as ResourceAnimatedCursor or
+	// ResourceAnimatedIcon resources. They are only supported in PE
+	// executables.
+	AnimatedCursors []*AnimatedCursor
+
+	Bitmaps []*Bitmap
+	Dialogs []*Dialog
+
+	Menus        []*Menu
+	Accelerators []*AcceleratorTable
+
+	// Fonts are stored as ResourceFont resources, along with a
+	// ResourceFontDir resource named FONTDIR that describes them.
+	Fonts []*Font
+
+	// Version, if non-nil, is stored as a ResourceVersion resource with ID 1.
+	Version *VersionInfo
+
+	// Manifest, if non-nil, is stored as a ResourceManifest resource.
+	Manifest *AppManifest
+
+	// Strings holds a string table for each language, stored as
+	// ResourceString resources.
+	Strings []*StringTable
+
+	// MessageTables are stored as ResourceMessageTable resources. They are
+	// only supported in PE executables.
+	MessageTables []*MessageTable
+
+	// Raw holds resources of any type that are stored as-is.
+	Raw []*RawResource
+
+	// Description is stored in the non-resident name table of NE
+	// executables. If empty and there are fonts, FontResourceDescription is
+	// used, as .fon files conventionally have it.
+	Description string
+
+	// ModuleName is stored in the resident name table of NE executables,
+	// and is the DLL name in the export directory of PE executables.
+	// Optional, unless there are exports.
+	ModuleName string
+
+	// Library marks executables as library modules, like .icl and .fon
+	// files or DLLs, rather than applications.
+	Library bool
+
+	// Program, if non-nil, adds a .text section with an entry point to PE
+	// executables, so that they can be run.
+	Program *Program
+
+	// Imports lists the DLLs that PE executables import functions from.
+	Imports []Import
+
+	// Exports lists the functions exported by PE executables, usually DLLs.
+	Exports []Export
+
+	// TLS, if non-nil, adds a .tls section with a TLS directory to PE
+	// executables.
+	TLS *TLS
+
+	// DynamicBase, HighEntropyVA and NXCompat set the DllCharacteristics of
+	// PE executables that mark them as compatible with ASLR, 64-bit ASLR
+	// and DEP. DynamicBase also makes them relocatable, as DLLs always are.
+	// HighEntropyVA is only supported in PE32+ executables, and requires
+	// DynamicBase.
+	DynamicBase   bool
+	HighEntropyVA bool
+	NXCompat      bool
+}
+
+// ResourceLanguage is the language of a resource, and the codepage recorded
+// for it.
+type ResourceLanguage struct {
+	// Language is the language ID of the resource.
+	Language uint16
+
+	// Codepage is recorded in the PE resource data entry. If zero,
+	// CodepageForLanguage is used.
+	Codepage uint32
+}
+
+func (l ResourceLanguage) codepage() uint32 {
+	if l.Codepage != 0 {
+		return l.Codepage
+	}
+	return CodepageForLanguage(l.Language)
+}
+
+// RawResource is a resource whose data is stored as-is, such as ResourceRCData
+// or a custom, string-named type.
+type RawResource struct {
+	Type ResourceName
+	Name ResourceName
+
+	ResourceLanguage
+
+	Data []byte
+}
+
+// NewRawResource creates a resource from data. The resource uses
+// DefaultLanguage.
+func NewRawResource(typ, name ResourceName, data []byte) *RawResource {
+	return &RawResource{Type: typ, Name: name, ResourceLanguage: ResourceLanguage{Language: DefaultLanguage}, Data: data}
+}
+
+// LoadRawResource creates a resource from the contents of a file, which must
+// not be empty. The resource uses DefaultLanguage.
+func LoadRawResource(typ, name ResourceName, path string) (*RawResource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	return NewRawResource(typ, name, data), nil
+}
+
+// Tree builds the resource tree for an executable of the given format.
+func (r *Resources) Tree(exeFormat EXEFormat) (*ResourceTree, error) {
+	tree := &ResourceTree{}
+	if err := r.addIcons(tree, exeFormat); err != nil {
+		return nil, err
+	}
+	if err := r.addCursors(tree); err != nil {
+		return nil, err
+	}
+	if err := r.addAnimatedCursors(tree, exeFormat); err != nil {
+		return nil, err
+	}
+	if err := r.addBitmaps(tree); err != nil {
+		return nil, err
+	}
+	if err := r.addDialogs(tree, exeFormat); err != nil {
+		return nil, err
+	}
+	if err := r.addFonts(tree); err != nil {
+		return nil, err
+	}
+	if err := r.addMenus(tree, exeFormat); err != nil {
+		return nil, err
+	}
+	if err := r.addAccelerators(tree, exeFormat); err != nil {
+		return nil, err
+	}
+	if err := r.addVersion(tree, exeFormat); err != nil {
+		return nil, err
+	}
+	if err := r.addManifest(tree, exeFormat); err != nil {
+		return nil, err
+	}
+	if err := r.addStrings(tree, exeFormat); err != nil {
+		return nil, err
+	}
+	if err := r.addMessageTables(tree, exeFormat); err != nil {
+		return nil, err
+	}
+	if err := r.addRaw(tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// neDescription returns the module description of NE executables.
+func (r *Resources) neDescription() string {
+	if r.Description == "" && len(r.Fonts) != 0 {
+		return FontResourceDescription(r.Fonts)
+	}
+	return r.Description
+}
+
+func (r *Resources) addIcons(tree *ResourceTree, exeFormat EXEFormat) error {
+	if len(r.Icons) == 0 {
+		return nil
+	}
+	if err := checkIconGroups(r.Icons, "icon"); err != nil {
+		return err
+	}
+
+	// Images are numbered sequentially across all groups.
+	iconID := 1
+	for _, group := range r.Icons {
+		codepage := group.codepage()
+		for _, img := range group.Images {
+			if _, ok := img.(*PNGIconImage); ok && exeFormat == NE16 {
+				return fmt.Errorf("icon group %s: PNG images are not supported in NE executables", group.Name)
+			}
+			buf := bytes.Buffer{}
+			img.Write(&buf)
+			if err := tree.Add(ResourceName{ID: ResourceIcon}, ResourceName{ID: uint16(iconID)}, group.Language, codepage, buf.Bytes()); err != nil {
+				return err
+			}
+			iconID++
+		}
+	}
+
+	iconID = 1
+	for _, group := range r.Icons {
+		buf := bytes.Buffer{}
+		group.WriteDirectory(&buf, iconID)
+		if err := tree.Add(ResourceName{ID: ResourceGroupIcon}, group.Name, group.Language, group.codepage(), buf.Bytes()); err != nil {
+			return err
+		}
+		iconID += len(group.Images)
+	}
+	return nil
+}
+
+func (r *Resources) addCursors(tree *ResourceTree) error {
+	if len(r.Cursors) == 0 {
+		return nil
+	}
+	if err := checkCursorGroups(r.Cursors); err != nil {
+		return err
+	}
+
+	// Like icon images, cursor images are numbered sequentially across all
+	// groups, but separately from icon images.
+	cursorID := 1
+	for _, group := range r.Cursors {
+		codepage := group.codepage()
+		for i := range group.Images {
+			buf := bytes.Buffer{}
+			group.WriteImage(&buf, i)
+			if err := tree.Add(ResourceName{ID: ResourceCursor}, ResourceName{ID: uint16(cursorID)}, group.Language, codepage, buf.Bytes()); err != nil {
+				return err
+			}
+			cursorID++
+		}
+	}
+
+	cursorID = 1
+	for _, group := range r.Cursors {
+		buf := bytes.Buffer{}
+		group.WriteDirectory(&buf, cursorID)
+		if err := tree.Add(ResourceName{ID: ResourceGroupCursor}, group.Name, group.Language, group.codepage(), buf.Bytes()); err != nil {
+			return err
+		}
+		cursorID += len(group.Images)
+	}
+	return nil
+}
+
+func (r *Resources) addAnimatedCursors(tree *ResourceTree, exeFormat EXEFormat) error {
+	if len(r.AnimatedCursors) == 0 {
+		return nil
+	}
+	if exeFormat == NE16 {
+		return errors.New("animated cursors are not supported in NE executables")
+	}
+	for _, ani := range r.AnimatedCursors {
+		buf := bytes.Buffer{}
+		if err := ani.WriteANI(&buf); err != nil {
+			return fmt.Errorf("animated cursor %s: %w", ani.Name, err)
+		}
+		typ := ResourceName{ID: ResourceAnimatedCursor}
+		if ani.Icon {
+			typ.ID = ResourceAnimatedIcon
+		}
+		if err := tree.Add(typ, ani.Name, ani.Language, ani.codepage(), buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resources) addBitmaps(tree *ResourceTree) error {
+	for _, bitmap := range r.Bitmaps {
+		buf := bytes.Buffer{}
+		bitmap.DIB.Write(&buf)
+		if err := tree.Add(ResourceName{ID: ResourceBitmap}, bitmap.Name, bitmap.Language, bitmap.codepage(), buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resources) addDialogs(tree *ResourceTree, exeFormat EXEFormat) error {
+	for _, dialog := range r.Dialogs {
+		data, err := dialog.Encode(exeFormat != NE16)
+		if err != nil {
+			return fmt.Errorf("dialog %s: %w", dialog.Name, err)
+		}
+		if err := tree.Add(ResourceName{ID: ResourceDialog}, dialog.Name, dialog.Language, dialog.codepage(), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resources) addFonts(tree *ResourceTree) error {
+	if len(r.Fonts) == 0 {
+		return nil
+	}
+	for _, font := range r.Fonts {
+		if font.Name.IsName() || font.Name.ID == 0 {
+			return fmt.Errorf("font %s: fonts must have integer IDs", font.Name)
+		}
+		data, err := font.Encode()
+		if err != nil {
+			return fmt.Errorf("font %s: %w", font.Name, err)
+		}
+		if err := tree.Add(ResourceName{ID: ResourceFont}, font.Name, font.Language, font.codepage(), data); err != nil {
+			return err
+		}
+	}
+	data, err := EncodeFontDir(r.Fonts)
+	if err != nil {
+		return err
+	}
+	lang := r.Fonts[0].Language
+	return tree.Add(ResourceName{ID: ResourceFontDir}, ResourceName{Name: "FONTDIR"}, lang, CodepageForLanguage(lang), data)
+}
+
+func (r *Resources) addMenus(tree *ResourceTree, exeFormat EXEFormat) error {
+	for _, menu := range r.Menus {
+		data, err := menu.Encode(exeFormat != NE16)
+		if err != nil {
+			return fmt.Errorf("menu %s: %w", menu.Name, err)
+		}
+		if err := tree.Add(ResourceName{ID: ResourceMenu}, menu.Name, menu.Language, menu.codepage(), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resources) addAccelerators(tree *ResourceTree, exeFormat EXEFormat) error {
+	for _, table := range r.Accelerators {
+		data, err := table.Encode(exeFormat != NE16)
+		if err != nil {
+			return fmt.Errorf("accelerators %s: %w", table.Name, err)
+		}
+		if err := tree.Add(ResourceName{ID: ResourceAccelerator}, table.Name, table.Language, table.codepage(), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileType returns the version file type of the executable: VFTFont for
+// libraries holding nothing but fonts, like .fon files, VFTDLL for other
+// libraries, and VFTApp otherwise.
+func (r *Resources) fileType() uint32 {
+	switch {
+	case !r.Library:
+		return VFTApp
+	case len(r.Fonts) != 0 && len(r.Icons) == 0 && r.Program == nil && len(r.Exports) == 0:
+		return VFTFont
+	default:
+		return VFTDLL
+	}
+}
+
+func (r *Resources) addVersion(tree *ResourceTree, exeFormat EXEFormat) error {
+	if r.Version == nil {
+		return nil
+	}
+	data, err := r.Version.Encode(exeFormat != NE16, r.fileType())
+	if err != nil {
+		return fmt.Errorf("version resource: %w", err)
+	}
+	if len(data) > 0xffff {
+		return fmt.Errorf("version resource too large (%d bytes)", len(data))
+	}
+	return tree.Add(ResourceName{ID: ResourceVersion}, ResourceName{ID: 1}, r.Version.Language, r.Version.codepage(), data)
+}
+
+func (r *Resources) addManifest(tree *ResourceTree, exeFormat EXEFormat) error {
+	if r.Manifest == nil {
+		return nil
+	}
+	if exeFormat == NE16 {
+		return errors.New("application manifests are not supported in NE executables")
+	}
+	data, err := r.Manifest.Encode()
+	if err != nil {
+		return fmt.Errorf("application manifest: %w", err)
+	}
+	return tree.Add(ResourceName{ID: ResourceManifest}, ResourceName{ID: r.Manifest.id(r.Library)}, r.Manifest.Language, r.Manifest.codepage(), data)
+}
+
+func (r *Resources) addStrings(tree *ResourceTree, exeFormat EXEFormat) error {
+	for _, table := range r.Strings {
+		for _, block := range table.Blocks() {
+			data, err := table.EncodeBlock(block, exeFormat != NE16)
+			if err != nil {
+				return fmt.Errorf("string table (language %#04x): %w", table.Language, err)
+			}
+			if err := tree.Add(ResourceName{ID: ResourceString}, ResourceName{ID: block}, table.Language, table.codepage(), data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Resources) addMessageTables(tree *ResourceTree, exeFormat EXEFormat) error {
+	if len(r.MessageTables) == 0 {
+		return nil
+	}
+	if exeFormat == NE16 {
+		return errors.New("message tables are not supported in NE executables")
+	}
+	for _, table := range r.MessageTables {
+		data, err := table.Encode()
+		if err != nil {
+			return fmt.Errorf("message table (language %#04x): %w", table.Language, err)
+		}
+		if err := tree.Add(ResourceName{ID: ResourceMessageTable}, table.name(), table.Language, table.codepage(), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resources) addRaw(tree *ResourceTree) error {
+	for _, raw := range r.Raw {
+		if raw.Type == (ResourceName{}) || raw.Name == (ResourceName{}) {
+			return errors.New("raw resources must have a type and a name")
+		}
+		if err := tree.Add(raw.Type, raw.Name, raw.Language, raw.codepage(), raw.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResourceName identifies a resource, either by an integer ID or by a string
+// name. If Name is non-empty, the resource is named and ID is ignored.
+type ResourceName struct {
+	ID   uint16
+	Name string
+}
+
+// IsName reports whether the resource is identified by a string name.
+func (n ResourceName) IsName() bool {
+	return n.Name != ""
+}
+
+func (n ResourceName) String() string {
+	if n.IsName() {
+		return fmt.Sprintf("%q", n.Name)
+	}
+	return fmt.Sprintf("#%d", n.ID)
+}
+
+// Less reports whether n sorts before o in a resource directory. Named
+// entries come first, in ascending order of their UTF-16 code units, and are
+// followed by ID entries in ascending order.
+func (n ResourceName) Less(o ResourceName) bool {
+	if n.IsName() != o.IsName() {
+		return n.IsName()
+	}
+	if !n.IsName() {
+		return n.ID < o.ID
+	}
+	a, b := utf16.Encode([]rune(n.Name)), utf16.Encode([]rune(o.Name))
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// resourceTypeNames maps the names of the standard resource types, without
+// the RT_ prefix, to their IDs.
+var resourceTypeNames = map[string]uint16{
+	"CURSOR":       ResourceCursor,
+	"BITMAP":       ResourceBitmap,
+	"ICON":         ResourceIcon,
+	"MENU":         ResourceMenu,
+	"DIALOG":       ResourceDialog,
+	"STRING":       ResourceString,
+	"FONTDIR":      ResourceFontDir,
+	"FONT":         ResourceFont,
+	"ACCELERATOR":  ResourceAccelerator,
+	"RCDATA":       ResourceRCData,
+	"MESSAGETABLE": ResourceMessageTable,
+	"GROUP_CURSOR": ResourceGroupCursor,
+	"GROUP_ICON":   ResourceGroupIcon,
+	"VERSION":      ResourceVersion,
+	"DLGINCLUDE":   ResourceDlgInclude,
+	"PLUGPLAY":     ResourcePlugPlay,
+	"VXD":          ResourceVXD,
+	"ANICURSOR":    ResourceAnimatedCursor,
+	"ANIICON":      ResourceAnimatedIcon,
+	"HTML":         ResourceHTML,
+	"MANIFEST":     ResourceManifest,
+}
+
+// ParseResourceName parses a resource name as written in resource scripts:
+// "#n" or a bare number is an integer ID, and anything else is a string
+// name.
+func ParseResourceName(s string) (ResourceName, error) {
+	if s == "" {
+		return ResourceName{}, errors.New("empty resource name")
+	}
+	if n, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 10, 16); err == nil {
+		if n == 0 {
+			return ResourceName{}, fmt.Errorf("invalid resource ID %q", s)
+		}
+		return ResourceName{ID: uint16(n)}, nil
+	} else if strings.HasPrefix(s, "#") {
+		return ResourceName{}, fmt.Errorf("invalid resource ID %q", s)
+	}
+	return normalizeResourceName(ResourceName{Name: s}), nil
+}
+
+// ParseResourceType parses a resource type. In addition to the syntax of
+// ParseResourceName, the names of the standard types, such as RCDATA or
+// RT_RCDATA, stand for their IDs.
+func ParseResourceType(s string) (ResourceName, error) {
+	if id, ok := resourceTypeNames[strings.TrimPrefix(strings.ToUpper(s), "RT_")]; ok {
+		return ResourceName{ID: id}, nil
+	}
+	return ParseResourceName(s)
+}
+
+// normalizeResourceName upper-cases string names, as resource compilers do;
+// FindResource upper-cases the names it looks up, so lower-case names could
+// never be found.
+func normalizeResourceName(n ResourceName) ResourceName {
+	if n.IsName() {
+		n.ID = 0
+		n.Name = strings.ToUpper(n.Name)
+	}
+	return n
+}
+
+// ansi encodes s in an ANSI codepage, for use in 16-bit structures. It
+// fails if the codepage is not supported or cannot represent s.
+func ansi(s string, codepage uint32) ([]byte, error) {
+	enc, ok := codepageEncodings[codepage]
+	if !ok {
+		return nil, fmt.Errorf("unsupported ANSI codepage %d", codepage)
+	}
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		return nil, fmt.Errorf("%q cannot be encoded in codepage %d", s, codepage)
+	}
+	return b, nil
+}
+
+// utf16z encodes s as a null-terminated UTF-16 string.
+func utf16z(s string) []byte {
+	buf := []byte{}
+	for _, c := range utf16.Encode([]rune(s)) {
+		buf = binary.LittleEndian.AppendUint16(buf, c)
+	}
+	return append(buf, 0, 0)
+}
+
+// ansiz encodes s as a null-terminated string in an ANSI codepage.
+func ansiz(s string, codepage uint32) ([]byte, error) {
+	b, err := ansi(s, codepage)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, 0), nil
+}
+
+// appendANSIZ appends s to buf as a null-terminated string in an ANSI
+// codepage.
+func appendANSIZ(buf []byte, s string, codepage uint32) ([]byte, error) {
+	b, err := ansiz(s, codepage)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, b...), nil
+}
+
+// pad32 pads buf with zeroes to a multiple of 4 bytes.
+func pad32(buf []byte) []byte {
+	return append(buf, make([]byte, align(len(buf), 4)-len(buf))...)
+}
@@ -0,0 +1,80 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestResourceNameLess(t *testing.T) {
+	names := []ResourceName{{ID: 10}, {Name: "b"}, {ID: 2}, {Name: "B"}, {Name: "AB"}, {Name: "A"}}
+	sort.Slice(names, func(i, j int) bool { return names[i].Less(names[j]) })
+	want := []ResourceName{{Name: "A"}, {Name: "AB"}, {Name: "B"}, {Name: "b"}, {ID: 2}, {ID: 10}}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("sorted names %v, want %v", names, want)
+	}
+}
+
+func TestNormalizeResourceName(t *testing.T) {
+	for _, test := range []struct{ name, want ResourceName }{
+		{ResourceName{ID: 5}, ResourceName{ID: 5}},
+		{ResourceName{Name: "MainIcon"}, ResourceName{Name: "MAINICON"}},
+		{ResourceName{ID: 5, Name: "x"}, ResourceName{Name: "X"}},
+	} {
+		if got := normalizeResourceName(test.name); got != test.want {
+			t.Errorf("normalizeResourceName(%v) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseResourceType(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want ResourceName
+		ok   bool
+	}{
+		{"RCDATA", ResourceName{ID: ResourceRCData}, true},
+		{"rt_rcdata", ResourceName{ID: ResourceRCData}, true},
+		{"#10", ResourceName{ID: 10}, true},
+		{"42", ResourceName{ID: 42}, true},
+		{"custom", ResourceName{Name: "CUSTOM"}, true},
+		{"", ResourceName{}, false},
+		{"#0", ResourceName{}, false},
+		{"#x", ResourceName{}, false},
+		{"#65536", ResourceName{}, false},
+	} {
+		got, err := ParseResourceType(test.s)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("ParseResourceType(%q) = %v, %v", test.s, got, err)
+		}
+	}
+}
+
+func TestRawResources(t *testing.T) {
+	raw := NewRawResource(ResourceName{Name: "custom"}, ResourceName{ID: 1}, []byte("mock"))
+	raw.Language, raw.Codepage = 0x0419, 1200
+	tree, err := (&Resources{Raw: []*RawResource{raw}}).Tree(PE32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.Buffer{}
+	tree.WritePE(&buf, 0x1000)
+	want := []peResource{{ResourceName{Name: "CUSTOM"}, ResourceName{ID: 1}, 0x0419, 1200, []byte("mock")}}
+	if got := readPEResources(t, buf.Bytes(), 0x1000); !reflect.DeepEqual(got, want) {
+		t.Errorf("resources %+v, want %+v", got, want)
+	}
+
+	if _, err := (&Resources{Raw: []*RawResource{raw, raw}}).Tree(PE32); err == nil {
+		t.Error("duplicate raw resources were accepted")
+	}
+}
@@ -0,0 +1,261 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"unicode/utf16"
+)
+
+// PEResourceDataAlignment is the alignment of resource data within the PE
+// resource section.
+const PEResourceDataAlignment = 8
+
+// ResourceTree holds the resources of an executable, organized the same way
+// as the PE resource directory: by type, then by name, then by language.
+// Entries are kept in the order they were added; the PE writer sorts them
+// as the format requires, while the NE writer preserves the order.
+type ResourceTree struct {
+	types []*resourceTypeNode
+}
+
+type resourceTypeNode struct {
+	typ   ResourceName
+	names []*resourceNameNode
+}
+
+type resourceNameNode struct {
+	name  ResourceName
+	langs []*resourceLangNode
+}
+
+type resourceLangNode struct {
+	lang     uint16
+	codepage uint32
+	data     []byte
+}
+
+// Add adds a resource to the tree. String names are upper-cased. It is an
+// error to add more than one resource with the same type, name and
+// language.
+func (t *ResourceTree) Add(typ, name ResourceName, lang uint16, codepage uint32, data []byte) error {
+	typ, name = normalizeResourceName(typ), normalizeResourceName(name)
+
+	var typeNode *resourceTypeNode
+	for _, node := range t.types {
+		if node.typ == typ {
+			typeNode = node
+			break
+		}
+	}
+	if typeNode == nil {
+		typeNode = &resourceTypeNode{typ: typ}
+		t.types = append(t.types, typeNode)
+	}
+
+	var nameNode *resourceNameNode
+	for _, node := range typeNode.names {
+		if node.name == name {
+			nameNode = node
+			break
+		}
+	}
+	if nameNode == nil {
+		nameNode = &resourceNameNode{name: name}
+		typeNode.names = append(typeNode.names, nameNode)
+	}
+
+	for _, node := range nameNode.langs {
+		if node.lang == lang {
+			return fmt.Errorf("duplicate resource: type %s, name %s, language %d", typ, name, lang)
+		}
+	}
+	nameNode.langs = append(nameNode.langs, &resourceLangNode{
+		lang:     lang,
+		codepage: codepage,
+		data:     data,
+	})
+	return nil
+}
+
+// peResourceDir is a directory table in the serialized resource tree.
+type peResourceDir struct {
+	offset  int
+	entries []peResourceEntry
+}
+
+// peResourceEntry is an entry of a peResourceDir. Exactly one of dir and
+// leaf is set.
+type peResourceEntry struct {
+	name ResourceName
+	dir  *peResourceDir
+	leaf *peResourceLeaf
+}
+
+// peResourceLeaf is a resource data entry and the data it points to.
+type peResourceLeaf struct {
+	*resourceLangNode
+	entryOffset int
+	dataOffset  int
+}
+
+// peResourceLayout describes the layout of a serialized resource tree. All
+// offsets are relative to the start of the resource section.
+type peResourceLayout struct {
+	dirs          []*peResourceDir
+	leaves        []*peResourceLeaf
+	strings       []string
+	stringOffsets map[string]int
+	stringsEnd    int
+	size          int
+}
+
+func sortedResourceNames[T any](nodes []T, name func(T) ResourceName) []T {
+	sorted := append([]T{}, nodes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return name(sorted[i]).Less(name(sorted[j]))
+	})
+	return sorted
+}
+
+// layoutPE computes the layout of the PE resource section. Directory tables
+// are stored breadth-first, followed by the data entries, the name strings
+// and finally the resource data itself.
+func (t *ResourceTree) layoutPE() *peResourceLayout {
+	l := &peResourceLayout{stringOffsets: map[string]int{}}
+
+	root := &peResourceDir{}
+	typeDirs := []*peResourceDir{}
+	nameDirs := []*peResourceDir{}
+	types := sortedResourceNames(t.types, func(n *resourceTypeNode) ResourceName { return n.typ })
+	for _, typeNode := range types {
+		typeDir := &peResourceDir{}
+		root.entries = append(root.entries, peResourceEntry{name: typeNode.typ, dir: typeDir})
+		typeDirs = append(typeDirs, typeDir)
+
+		names := sortedResourceNames(typeNode.names, func(n *resourceNameNode) ResourceName { return n.name })
+		for _, nameNode := range names {
+			nameDir := &peResourceDir{}
+			typeDir.entries = append(typeDir.entries, peResourceEntry{name: nameNode.name, dir: nameDir})
+			nameDirs = append(nameDirs, nameDir)
+
+			langs := sortedResourceNames(nameNode.langs, func(n *resourceLangNode) ResourceName { return ResourceName{ID: n.lang} })
+			for _, langNode := range langs {
+				leaf := &peResourceLeaf{resourceLangNode: langNode}
+				nameDir.entries = append(nameDir.entries, peResourceEntry{name: ResourceName{ID: langNode.lang}, leaf: leaf})
+				l.leaves = append(l.leaves, leaf)
+			}
+		}
+	}
+	l.dirs = append(append([]*peResourceDir{root}, typeDirs...), nameDirs...)
+
+	offset := 0
+	for _, dir := range l.dirs {
+		dir.offset = offset
+		offset += SizeOfResourceDirectoryTable + len(dir.entries)*SizeOfResourceDirectoryEntry
+	}
+	for _, leaf := range l.leaves {
+		leaf.entryOffset = offset
+		offset += SizeOfResourceDataEntry
+	}
+	for _, dir := range l.dirs {
+		for _, entry := range dir.entries {
+			if !entry.name.IsName() {
+				continue
+			}
+			if _, ok := l.stringOffsets[entry.name.Name]; ok {
+				continue
+			}
+			l.strings = append(l.strings, entry.name.Name)
+			l.stringOffsets[entry.name.Name] = offset
+			offset += 2 + 2*len(utf16.Encode([]rune(entry.name.Name)))
+		}
+	}
+	l.stringsEnd = offset
+	for _, leaf := range l.leaves {
+		offset = align(offset, PEResourceDataAlignment)
+		leaf.dataOffset = offset
+		offset += len(leaf.data)
+	}
+	l.size = offset
+	return l
+}
+
+// PESize returns the size of the tree when serialized as a PE resource
+// section.
+func (t *ResourceTree) PESize() int {
+	return t.layoutPE().size
+}
+
+// WritePE serializes the tree as a PE resource section that will be loaded
+// at the given RVA.
+func (t *ResourceTree) WritePE(w io.Writer, rva uint32) {
+	l := t.layoutPE()
+
+	for _, dir := range l.dirs {
+		numNames := 0
+		for _, entry := range dir.entries {
+			if entry.name.IsName() {
+				numNames++
+			}
+		}
+		must(binary.Write(w, binary.LittleEndian, ResourceDirectoryTable{
+			NumNameEntries: uint16(numNames),
+			NumIDEntries:   uint16(len(dir.entries) - numNames),
+			MajorVersion:   4,
+		}), "writing resource dir")
+		for _, entry := range dir.entries {
+			id := uint32(entry.name.ID)
+			if entry.name.IsName() {
+				id = 0x80000000 | uint32(l.stringOffsets[entry.name.Name])
+			}
+			offset := uint32(0)
+			if entry.dir != nil {
+				offset = 0x80000000 | uint32(entry.dir.offset)
+			} else {
+				offset = uint32(entry.leaf.entryOffset)
+			}
+			must(binary.Write(w, binary.LittleEndian, ResourceDirectoryEntry{
+				ID:     id,
+				Offset: offset,
+			}), "writing resource dir entry")
+		}
+	}
+
+	for _, leaf := range l.leaves {
+		must(binary.Write(w, binary.LittleEndian, ResourceDataEntry{
+			DataRVA:  rva + uint32(leaf.dataOffset),
+			Size:     uint32(len(leaf.data)),
+			Codepage: leaf.codepage,
+		}), "writing resource data entry")
+	}
+
+	for _, name := range l.strings {
+		s := utf16.Encode([]rune(name))
+		must(binary.Write(w, binary.LittleEndian, uint16(len(s))), "writing resource name length")
+		must(binary.Write(w, binary.LittleEndian, s), "writing resource name")
+	}
+
+	offset := l.stringsEnd
+	for _, leaf := range l.leaves {
+		_, err := w.Write(make([]byte, leaf.dataOffset-offset))
+		must(err, "writing resource data padding")
+		_, err = w.Write(leaf.data)
+		must(err, "writing resource data")
+		offset = leaf.dataOffset + len(leaf.data)
+	}
+}
+
+func align(n, alignment int) int {
+	return (n + alignment - 1) &^ (alignment - 1)
+}
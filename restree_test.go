@@ -0,0 +1,132 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+// peResource is a resource read back from a PE resource section.
+type peResource struct {
+	typ, name ResourceName
+	lang      uint16
+	codepage  uint32
+	data      []byte
+}
+
+// readPEResources walks the resource section data loaded at rva, returning
+// the resources in directory order.
+func readPEResources(t *testing.T, data []byte, rva uint32) []peResource {
+	t.Helper()
+	le := binary.LittleEndian
+	entries := func(offset uint32) (names []ResourceName, offsets []uint32) {
+		table := ResourceDirectoryTable{}
+		if err := binary.Read(bytes.NewReader(data[offset:]), le, &table); err != nil {
+			t.Fatal(err)
+		}
+		offset += SizeOfResourceDirectoryTable
+		for i := 0; i < int(table.NumNameEntries)+int(table.NumIDEntries); i++ {
+			id, target := le.Uint32(data[offset:]), le.Uint32(data[offset+4:])
+			offset += SizeOfResourceDirectoryEntry
+			if (id&0x80000000 != 0) != (i < int(table.NumNameEntries)) {
+				t.Fatalf("entry %d of the directory at %#x is out of place", i, offset)
+			}
+			name := ResourceName{ID: uint16(id)}
+			if id&0x80000000 != 0 {
+				s := id &^ 0x80000000
+				n := uint32(le.Uint16(data[s:]))
+				units := make([]uint16, n)
+				if err := binary.Read(bytes.NewReader(data[s+2:s+2+2*n]), le, units); err != nil {
+					t.Fatal(err)
+				}
+				name = ResourceName{Name: string(utf16.Decode(units))}
+			}
+			names, offsets = append(names, name), append(offsets, target)
+		}
+		return names, offsets
+	}
+
+	resources := []peResource{}
+	types, typeOffsets := entries(0)
+	for i, typ := range types {
+		names, nameOffsets := entries(typeOffsets[i] &^ 0x80000000)
+		for j, name := range names {
+			langs, leafOffsets := entries(nameOffsets[j] &^ 0x80000000)
+			for k, lang := range langs {
+				leaf := ResourceDataEntry{}
+				if err := binary.Read(bytes.NewReader(data[leafOffsets[k]:]), le, &leaf); err != nil {
+					t.Fatal(err)
+				}
+				if leaf.DataRVA%PEResourceDataAlignment != 0 {
+					t.Errorf("resource data at %#x is misaligned", leaf.DataRVA)
+				}
+				offset := leaf.DataRVA - rva
+				resources = append(resources, peResource{
+					typ:      typ,
+					name:     name,
+					lang:     lang.ID,
+					codepage: leaf.Codepage,
+					data:     data[offset : offset+leaf.Size],
+				})
+			}
+		}
+	}
+	return resources
+}
+
+func TestResourceTreeWritePE(t *testing.T) {
+	tree := &ResourceTree{}
+	add := func(typ, name ResourceName, lang uint16, data string) {
+		t.Helper()
+		if err := tree.Add(typ, name, lang, 1252, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add(ResourceName{ID: ResourceIcon}, ResourceName{ID: 2}, 0x0409, "two")
+	add(ResourceName{ID: ResourceIcon}, ResourceName{ID: 1}, 0x0409, "one")
+	add(ResourceName{Name: "custom"}, ResourceName{Name: "data"}, 0x0409, "custom")
+	add(ResourceName{ID: ResourceIcon}, ResourceName{ID: 1}, 0x0407, "eins")
+	add(ResourceName{ID: ResourceIcon}, ResourceName{Name: "Named"}, 0x0409, "named")
+
+	const rva = 0x3000
+	buf := bytes.Buffer{}
+	tree.WritePE(&buf, rva)
+	if buf.Len() != tree.PESize() {
+		t.Errorf("wrote %d bytes, PESize is %d", buf.Len(), tree.PESize())
+	}
+	want := []peResource{
+		{ResourceName{Name: "CUSTOM"}, ResourceName{Name: "DATA"}, 0x0409, 1252, []byte("custom")},
+		{ResourceName{ID: ResourceIcon}, ResourceName{Name: "NAMED"}, 0x0409, 1252, []byte("named")},
+		{ResourceName{ID: ResourceIcon}, ResourceName{ID: 1}, 0x0407, 1252, []byte("eins")},
+		{ResourceName{ID: ResourceIcon}, ResourceName{ID: 1}, 0x0409, 1252, []byte("one")},
+		{ResourceName{ID: ResourceIcon}, ResourceName{ID: 2}, 0x0409, 1252, []byte("two")},
+	}
+	if got := readPEResources(t, buf.Bytes(), rva); !reflect.DeepEqual(got, want) {
+		t.Errorf("resources %+v, want %+v", got, want)
+	}
+}
+
+func TestResourceTreeDuplicate(t *testing.T) {
+	tree := &ResourceTree{}
+	typ := ResourceName{ID: ResourceIcon}
+	if err := tree.Add(typ, ResourceName{Name: "data"}, 0x0409, 1252, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Add(typ, ResourceName{Name: "data"}, 0x0407, 1252, nil); err != nil {
+		t.Errorf("resource in another language: %v", err)
+	}
+	if err := tree.Add(typ, ResourceName{Name: "DATA"}, 0x0409, 1252, nil); err == nil {
+		t.Error("duplicate resource was added")
+	}
+}
@@ -0,0 +1,79 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"unicode/utf16"
+)
+
+// StringsPerBlock is the number of strings in each ResourceString resource.
+const StringsPerBlock = 16
+
+// StringTable is a set of strings identified by integer IDs. In executables,
+// the strings are stored in blocks of StringsPerBlock consecutive IDs; the
+// block containing string n has the resource ID (n>>4)+1.
+type StringTable struct {
+	// ResourceLanguage applies to each of the blocks.
+	ResourceLanguage
+
+	Strings map[uint16]string
+}
+
+// Blocks returns the IDs of the blocks that contain strings, in ascending
+// order.
+func (t *StringTable) Blocks() []uint16 {
+	seen := map[uint16]bool{}
+	blocks := []uint16{}
+	for id := range t.Strings {
+		block := id>>4 + 1
+		if !seen[block] {
+			seen[block] = true
+			blocks = append(blocks, block)
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+	return blocks
+}
+
+// EncodeBlock encodes one block of the string table. Each string is prefixed
+// with its length: in PE executables, strings are UTF-16 with a 16-bit
+// length in code units, while in NE executables, they are ANSI with an 8-bit
+// length in bytes. Missing strings are stored with a length of zero.
+func (t *StringTable) EncodeBlock(block uint16, unicode bool) ([]byte, error) {
+	buf := []byte{}
+	first := (block - 1) << 4
+	for i := uint16(0); i < StringsPerBlock; i++ {
+		s := t.Strings[first+i]
+		if unicode {
+			units := utf16.Encode([]rune(s))
+			if len(units) > 0xffff {
+				return nil, fmt.Errorf("string %d is too long (%d characters)", first+i, len(units))
+			}
+			buf = binary.LittleEndian.AppendUint16(buf, uint16(len(units)))
+			for _, c := range units {
+				buf = binary.LittleEndian.AppendUint16(buf, c)
+			}
+		} else {
+			b, err := ansi(s, t.codepage())
+			if err != nil {
+				return nil, fmt.Errorf("string %d: %w", first+i, err)
+			}
+			if len(b) > 0xff {
+				return nil, fmt.Errorf("string %d is too long (%d bytes)", first+i, len(b))
+			}
+			buf = append(buf, byte(len(b)))
+			buf = append(buf, b...)
+		}
+	}
+	return buf, nil
+}
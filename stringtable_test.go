@@ -0,0 +1,72 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testStringTable() *StringTable {
+	return &StringTable{
+		ResourceLanguage: ResourceLanguage{Language: 0x0407},
+		Strings:          map[uint16]string{1: "Ja", 3: "Grüße", 40: "X"},
+	}
+}
+
+func TestStringTableBlocks(t *testing.T) {
+	if got, want := testStringTable().Blocks(), []uint16{1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Blocks() = %v, want %v", got, want)
+	}
+}
+
+func TestStringTableEncodeBlock(t *testing.T) {
+	table := testStringTable()
+	got, err := table.EncodeBlock(1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := unhex(t, `
+		00 00  02 00 4a 00 61 00  00 00  05 00 47 00 72 00 fc 00 df 00 65 00
+		00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+	`)
+	if !bytes.Equal(got, want) {
+		t.Errorf("EncodeBlock(1, true) = % x, want % x", got, want)
+	}
+
+	got, err = table.EncodeBlock(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = unhex(t, `00  02 4a 61  00  05 47 72 fc df 65  00 00 00 00 00 00 00 00 00 00 00 00`)
+	if !bytes.Equal(got, want) {
+		t.Errorf("EncodeBlock(1, false) = % x, want % x", got, want)
+	}
+
+	got, err = table.EncodeBlock(3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(make([]byte, 2*8), 1, 0, 'X', 0); !bytes.Equal(got[:len(want)], want) || len(got) != 2*16+2 {
+		t.Errorf("EncodeBlock(3, true) = % x", got)
+	}
+}
+
+func TestStringTableEncodeBlockTooLong(t *testing.T) {
+	table := &StringTable{Strings: map[uint16]string{0: strings.Repeat("x", 0x100)}}
+	if _, err := table.EncodeBlock(1, false); err == nil {
+		t.Error("EncodeBlock(1, false) succeeded with a 256-byte string")
+	}
+	if _, err := table.EncodeBlock(1, true); err != nil {
+		t.Errorf("EncodeBlock(1, true): %v", err)
+	}
+}
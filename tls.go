@@ -0,0 +1,88 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// TLS describes the thread local storage of a PE executable.
+type TLS struct {
+	// Data is the initial value of the TLS data of each thread. Optional.
+	Data []byte
+
+	// ZeroFill is the number of zero bytes that follow Data in the TLS data
+	// of each thread.
+	ZeroFill uint32
+
+	// Callbacks is the number of TLS callbacks. Each is a separate function
+	// that returns immediately.
+	Callbacks int
+}
+
+// encodeTLS encodes the contents of a .tls section at rva of an executable
+// based at imageBase: Data, the TLS
+// index, the null-terminated array of callbacks, which are at callbackRVAs,
+// and the TLS directory. Addresses are 64-bit in PE32+ executables. It also
+// returns the offsets of the absolute addresses, which need relocations,
+// and the offset of the directory.
+func encodeTLS(tls *TLS, exeFormat EXEFormat, imageBase uint64, rva uint32, callbackRVAs []int) (data []byte, relocs []int, directory int) {
+	le := binary.LittleEndian
+	pointerSize := 4
+	if exeFormat == PE32Plus {
+		pointerSize = 8
+	}
+	va := func(offset int) uint64 {
+		return imageBase + uint64(rva) + uint64(offset)
+	}
+	appendPointer := func(buf []byte, address uint64) []byte {
+		relocs = append(relocs, len(buf))
+		if exeFormat == PE32Plus {
+			return le.AppendUint64(buf, address)
+		}
+		return le.AppendUint32(buf, uint32(address))
+	}
+
+	data = pad32(append([]byte{}, tls.Data...))
+	index := len(data)
+	data = le.AppendUint32(data, 0)
+	data = append(data, make([]byte, align(len(data), pointerSize)-len(data))...)
+	callbacks := len(data)
+	for _, callback := range callbackRVAs {
+		data = appendPointer(data, imageBase+uint64(callback))
+	}
+	data = append(data, make([]byte, pointerSize)...)
+
+	directory = len(data)
+	header := ImageTLSDirectoryPE32Plus{
+		StartAddressOfRawData: va(0),
+		EndAddressOfRawData:   va(len(tls.Data)),
+		AddressOfIndex:        va(index),
+		AddressOfCallBacks:    va(callbacks),
+		SizeOfZeroFill:        tls.ZeroFill,
+	}
+	for i := 0; i < 4; i++ {
+		relocs = append(relocs, directory+i*pointerSize)
+	}
+	buf := bytes.Buffer{}
+	if exeFormat == PE32Plus {
+		must(binary.Write(&buf, le, header), "writing TLS directory")
+	} else {
+		must(binary.Write(&buf, le, ImageTLSDirectoryPE32{
+			StartAddressOfRawData: uint32(header.StartAddressOfRawData),
+			EndAddressOfRawData:   uint32(header.EndAddressOfRawData),
+			AddressOfIndex:        uint32(header.AddressOfIndex),
+			AddressOfCallBacks:    uint32(header.AddressOfCallBacks),
+			SizeOfZeroFill:        header.SizeOfZeroFill,
+		}), "writing TLS directory")
+	}
+	return append(data, buf.Bytes()...), relocs, directory
+}
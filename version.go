@@ -0,0 +1,326 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// VSFixedFileInfoSignature is the value of the Signature field in
+	// VSFixedFileInfo.
+	VSFixedFileInfoSignature = 0xfeef04bd
+
+	// SizeOfVSFixedFileInfo is the on-disk size of VSFixedFileInfo.
+	SizeOfVSFixedFileInfo = 52
+
+	// CodepageUnicode is the codepage of UTF-16 string tables.
+	CodepageUnicode = 1200
+)
+
+// Enumeration of version file flags.
+const (
+	VSFFDebug        = 0x01
+	VSFFPrerelease   = 0x02
+	VSFFPatched      = 0x04
+	VSFFPrivateBuild = 0x08
+	VSFFInfoInferred = 0x10
+	VSFFSpecialBuild = 0x20
+)
+
+// Enumeration of version file operating systems.
+const (
+	VOSUnknown      = 0x00000000
+	VOSDOSWindows16 = 0x00010001
+	VOSDOSWindows32 = 0x00010004
+	VOSNTWindows32  = 0x00040004
+)
+
+// Enumeration of version file types.
+const (
+	VFTUnknown   = 0
+	VFTApp       = 1
+	VFTDLL       = 2
+	VFTDriver    = 3
+	VFTFont      = 4
+	VFTVXD       = 5
+	VFTStaticLib = 7
+)
+
+// Enumeration of version file subtypes of fonts.
+const (
+	VFT2FontRaster   = 1
+	VFT2FontVector   = 2
+	VFT2FontTrueType = 3
+)
+
+// VSFixedFileInfo is the language-independent part of a version resource.
+type VSFixedFileInfo struct {
+	Signature        uint32
+	StrucVersion     uint32
+	FileVersionMS    uint32
+	FileVersionLS    uint32
+	ProductVersionMS uint32
+	ProductVersionLS uint32
+	FileFlagsMask    uint32
+	FileFlags        uint32
+	FileOS           uint32
+	FileType         uint32
+	FileSubtype      uint32
+	FileDateMS       uint32
+	FileDateLS       uint32
+}
+
+// VersionTranslation identifies the language and codepage of a block of
+// version strings.
+type VersionTranslation struct {
+	Language uint16
+
+	// Codepage of the strings. If zero, 1200 (Unicode) is used in PE
+	// executables and the ANSI codepage of the language in NE executables.
+	Codepage uint16
+}
+
+// VersionString is a single key/value pair in a version string table, such
+// as CompanyName or FileVersion.
+type VersionString struct {
+	Key   string
+	Value string
+}
+
+// VersionStringTable is a block of version strings for one translation.
+type VersionStringTable struct {
+	VersionTranslation
+	Strings []VersionString
+}
+
+// VersionInfo describes the contents of a VS_VERSIONINFO resource.
+type VersionInfo struct {
+	// ResourceLanguage applies to the resource itself, and to the keys
+	// outside of the string tables in NE executables.
+	ResourceLanguage
+
+	FileVersion    [4]uint16
+	ProductVersion [4]uint16
+	FileFlagsMask  uint32
+	FileFlags      uint32
+
+	// FileOS defaults to VOSNTWindows32 in PE executables and
+	// VOSDOSWindows16 in NE executables.
+	FileOS uint32
+
+	// FileType defaults to the type of the executable the resource is
+	// stored in. FileSubtype defaults to VFT2FontRaster for fonts.
+	FileType    uint32
+	FileSubtype uint32
+
+	// StringTables are stored in the StringFileInfo block.
+	StringTables []VersionStringTable
+
+	// Translations are stored in the VarFileInfo block. If empty, there is
+	// one for each string table.
+	Translations []VersionTranslation
+}
+
+// ParseVersion parses a dotted version number with up to four parts, such
+// as "1.2.3.4". Missing parts are zero.
+func ParseVersion(s string) ([4]uint16, error) {
+	v := [4]uint16{}
+	parts := strings.Split(s, ".")
+	if len(parts) > 4 {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 16)
+		if err != nil {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		v[i] = uint16(n)
+	}
+	return v, nil
+}
+
+// versionNode is a block of a version resource. All blocks share the same
+// layout: a header, a key, an optional value and optional child blocks,
+// each aligned to 32 bits.
+type versionNode struct {
+	key string
+
+	// value is the encoded value, and valueLength the value of the
+	// wValueLength field, whose units vary by block.
+	value       []byte
+	valueLength int
+
+	// text is true for blocks containing text, rather than binary, data.
+	// It sets the wType field in the 32-bit layout.
+	text bool
+
+	// codepage, if not zero, is the ANSI codepage of the key of the block
+	// and of its children in the 16-bit layout. Otherwise, the codepage of
+	// the parent is used.
+	codepage uint32
+
+	children []versionNode
+}
+
+// encode encodes the block in either the 32-bit layout, which has a wType
+// field and UTF-16 strings, or the older 16-bit layout, which has neither
+// and whose strings are in an ANSI codepage.
+func (n *versionNode) encode(unicode bool, codepage uint32) ([]byte, error) {
+	if n.codepage != 0 {
+		codepage = n.codepage
+	}
+	buf := []byte{}
+	if unicode {
+		buf = append(buf, make([]byte, 6)...)
+		buf = append(buf, utf16z(n.key)...)
+	} else {
+		var err error
+		if buf, err = appendANSIZ(make([]byte, 4), n.key, codepage); err != nil {
+			return nil, err
+		}
+	}
+	buf = pad32(buf)
+	buf = append(buf, n.value...)
+	for _, child := range n.children {
+		buf = pad32(buf)
+		b, err := child.encode(unicode, codepage)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+	binary.LittleEndian.PutUint16(buf[0:], uint16(len(buf)))
+	binary.LittleEndian.PutUint16(buf[2:], uint16(n.valueLength))
+	if unicode && n.text {
+		binary.LittleEndian.PutUint16(buf[4:], 1)
+	}
+	return buf, nil
+}
+
+func (v *VersionInfo) translations(unicode bool) []VersionTranslation {
+	translations := v.Translations
+	if len(translations) == 0 {
+		for _, table := range v.StringTables {
+			translations = append(translations, table.VersionTranslation)
+		}
+	}
+	resolved := []VersionTranslation{}
+	for _, t := range translations {
+		resolved = append(resolved, t.resolve(unicode))
+	}
+	return resolved
+}
+
+func (t VersionTranslation) resolve(unicode bool) VersionTranslation {
+	if t.Codepage == 0 {
+		if unicode {
+			t.Codepage = CodepageUnicode
+		} else {
+			t.Codepage = uint16(CodepageForLanguage(t.Language))
+		}
+	}
+	return t
+}
+
+// Encode encodes the version resource. If unicode is true, the 32-bit
+// layout used by PE executables is produced; otherwise, the 16-bit layout
+// used by NE executables is, with the strings of each table in the codepage
+// of its translation. fileType is the type of the executable, used if
+// FileType is zero.
+func (v *VersionInfo) Encode(unicode bool, fileType uint32) ([]byte, error) {
+	fixed := VSFixedFileInfo{
+		Signature:        VSFixedFileInfoSignature,
+		StrucVersion:     0x00010000,
+		FileVersionMS:    uint32(v.FileVersion[0])<<16 | uint32(v.FileVersion[1]),
+		FileVersionLS:    uint32(v.FileVersion[2])<<16 | uint32(v.FileVersion[3]),
+		ProductVersionMS: uint32(v.ProductVersion[0])<<16 | uint32(v.ProductVersion[1]),
+		ProductVersionLS: uint32(v.ProductVersion[2])<<16 | uint32(v.ProductVersion[3]),
+		FileFlagsMask:    v.FileFlagsMask,
+		FileFlags:        v.FileFlags,
+		FileOS:           v.FileOS,
+		FileType:         v.FileType,
+		FileSubtype:      v.FileSubtype,
+	}
+	if fixed.FileOS == 0 {
+		if unicode {
+			fixed.FileOS = VOSNTWindows32
+		} else {
+			fixed.FileOS = VOSDOSWindows16
+		}
+	}
+	if fixed.FileType == 0 {
+		fixed.FileType = fileType
+		if fileType == VFTFont && fixed.FileSubtype == 0 {
+			fixed.FileSubtype = VFT2FontRaster
+		}
+	}
+	fixedBuf := bytes.Buffer{}
+	must(binary.Write(&fixedBuf, binary.LittleEndian, fixed), "writing fixed file info")
+
+	root := versionNode{
+		key:         "VS_VERSION_INFO",
+		value:       fixedBuf.Bytes(),
+		valueLength: SizeOfVSFixedFileInfo,
+	}
+
+	if len(v.StringTables) > 0 {
+		stringFileInfo := versionNode{key: "StringFileInfo", text: true}
+		for _, table := range v.StringTables {
+			t := table.resolve(unicode)
+			tableNode := versionNode{
+				key:  fmt.Sprintf("%04x%04x", t.Language, t.Codepage),
+				text: true,
+			}
+			if !unicode {
+				tableNode.codepage = uint32(t.Codepage)
+			}
+			for _, s := range table.Strings {
+				node := versionNode{key: s.Key, text: true}
+				if unicode {
+					node.value = utf16z(s.Value)
+					node.valueLength = len(node.value) / 2
+				} else {
+					var err error
+					if node.value, err = ansiz(s.Value, uint32(t.Codepage)); err != nil {
+						return nil, fmt.Errorf("string %s: %w", s.Key, err)
+					}
+					node.valueLength = len(node.value)
+				}
+				tableNode.children = append(tableNode.children, node)
+			}
+			stringFileInfo.children = append(stringFileInfo.children, tableNode)
+		}
+		root.children = append(root.children, stringFileInfo)
+	}
+
+	if translations := v.translations(unicode); len(translations) > 0 {
+		value := []byte{}
+		for _, t := range translations {
+			value = binary.LittleEndian.AppendUint16(value, t.Language)
+			value = binary.LittleEndian.AppendUint16(value, t.Codepage)
+		}
+		root.children = append(root.children, versionNode{
+			key:  "VarFileInfo",
+			text: true,
+			children: []versionNode{{
+				key:         "Translation",
+				value:       value,
+				valueLength: len(value),
+			}},
+		})
+	}
+
+	return root.encode(unicode, v.codepage())
+}
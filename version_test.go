@@ -0,0 +1,97 @@
+// make-mock-exe by John Chadwick <john@jchw.io>
+//
+// To the extent possible under law, the person who associated CC0 with
+// make-mock-exe has waived all copyright and related or neighboring rights
+// to make-mock-exe.
+//
+// You should have received a copy of the CC0 legalcode along with this
+// work.  If not, see <http://creativecommons.org/publicdomain/zero/1.0/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func testVersionInfo() *VersionInfo {
+	return &VersionInfo{
+		FileVersion:    [4]uint16{1, 2, 3, 4},
+		ProductVersion: [4]uint16{1, 2, 3, 4},
+		StringTables: []VersionStringTable{{
+			VersionTranslation: VersionTranslation{Language: 0x0409},
+			Strings:            []VersionString{{Key: "A", Value: "B"}},
+		}},
+	}
+}
+
+func TestVersionInfoEncode(t *testing.T) {
+	want := unhex(t, `
+		ec 00 34 00 00 00 56 00 53 00 5f 00 56 00 45 00
+		52 00 53 00 49 00 4f 00 4e 00 5f 00 49 00 4e 00
+		46 00 4f 00 00 00 00 00 bd 04 ef fe 00 00 01 00
+		02 00 01 00 04 00 03 00 02 00 01 00 04 00 03 00
+		00 00 00 00 00 00 00 00 04 00 04 00 01 00 00 00
+		00 00 00 00 00 00 00 00 00 00 00 00 4c 00 00 00
+		01 00 53 00 74 00 72 00 69 00 6e 00 67 00 46 00
+		69 00 6c 00 65 00 49 00 6e 00 66 00 6f 00 00 00
+		28 00 00 00 01 00 30 00 34 00 30 00 39 00 30 00
+		34 00 62 00 30 00 00 00 10 00 02 00 01 00 41 00
+		00 00 00 00 42 00 00 00 44 00 00 00 01 00 56 00
+		61 00 72 00 46 00 69 00 6c 00 65 00 49 00 6e 00
+		66 00 6f 00 00 00 00 00 24 00 04 00 00 00 54 00
+		72 00 61 00 6e 00 73 00 6c 00 61 00 74 00 69 00
+		6f 00 6e 00 00 00 00 00 09 04 b0 04
+	`)
+	got, err := testVersionInfo().Encode(true, VFTApp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Encode(true, VFTApp) =\n%s\nwant\n%s", hex.Dump(got), hex.Dump(want))
+	}
+}
+
+func TestVersionInfoEncode16(t *testing.T) {
+	want := unhex(t, `
+		9c 00 34 00 56 53 5f 56 45 52 53 49 4f 4e 5f 49
+		4e 46 4f 00 bd 04 ef fe 00 00 01 00 02 00 01 00
+		04 00 03 00 02 00 01 00 04 00 03 00 00 00 00 00
+		00 00 00 00 01 00 01 00 04 00 00 00 01 00 00 00
+		00 00 00 00 00 00 00 00 2e 00 00 00 53 74 72 69
+		6e 67 46 69 6c 65 49 6e 66 6f 00 00 1a 00 00 00
+		30 34 30 39 30 34 65 34 00 00 00 00 0a 00 02 00
+		41 00 00 00 42 00 00 00 24 00 00 00 56 61 72 46
+		69 6c 65 49 6e 66 6f 00 14 00 04 00 54 72 61 6e
+		73 6c 61 74 69 6f 6e 00 09 04 e4 04
+	`)
+	got, err := testVersionInfo().Encode(false, VFTFont)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Encode(false, VFTFont) =\n%s\nwant\n%s", hex.Dump(got), hex.Dump(want))
+	}
+}
+
+func TestVersionInfoEncode16Codepage(t *testing.T) {
+	v := testVersionInfo()
+	v.StringTables[0].Language = 0x0419
+	v.StringTables[0].Strings[0].Value = "Привет"
+	got, err := v.Encode(false, VFTApp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte("041904e3"); !bytes.Contains(got, want) {
+		t.Errorf("Encode(false, VFTApp) has no %s table:\n%s", want, hex.Dump(got))
+	}
+	if want := []byte("\xcf\xf0\xe8\xe2\xe5\xf2\x00"); !bytes.Contains(got, want) {
+		t.Errorf("Encode(false, VFTApp) has no value in codepage 1251:\n%s", hex.Dump(got))
+	}
+
+	v.StringTables[0].Language = 0x0409
+	if _, err := v.Encode(false, VFTApp); err == nil {
+		t.Error("Encode(false, VFTApp) succeeded with characters outside of codepage 1252")
+	}
+}